@@ -0,0 +1,184 @@
+package serpent
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// maxBatchLineSize is the maximum length of a line of a batch script.
+const maxBatchLineSize = 1 << 20
+
+// BatchResult reports how a line of a batch ran. With --json, the batch
+// command writes one per line, as a JSON object on its own line.
+type BatchResult struct {
+	// Line is the line number in the script, starting at 1.
+	Line    int    `json:"line"`
+	Command string `json:"command"`
+	// ExitCode is 0 if the command succeeded. Errors that implement
+	// ExitCode() int set it, and other errors make it 1.
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+	Stdout   string `json:"stdout"`
+	Stderr   string `json:"stderr"`
+}
+
+// BatchCommand returns a "batch" command that runs the newline-separated,
+// shell-quoted command lines of a script file, or of Stdin if the file is
+// omitted or "-". Each line is a fresh invocation of the root command,
+// without the program name, with the options reset as in REPLCommand.
+// Empty lines and lines starting with "#" are skipped.
+//
+// Flags given before "batch" apply to every line, and all lines run in one
+// session, so clients stored with SessionValue are set up only once. The
+// batch stops at the first failing line, unless --continue-on-error is
+// given.
+func BatchCommand() *Command {
+	return &Command{
+		Use:   "batch [file]",
+		Short: "Run commands from a script file or stdin.",
+		Long: "Run the commands of a script, one per line, without the program name. " +
+			"Arguments are quoted as in a POSIX shell.",
+		Middleware: RequireRangeArgs(0, 1),
+		Options: OptionSet{
+			{
+				Name:        "continue-on-error",
+				Flag:        "continue-on-error",
+				Description: "Run the remaining lines after a line fails.",
+				Value:       BoolOf(new(bool)),
+			},
+			{
+				Name:        "json",
+				Flag:        "json",
+				Description: "Report the output and exit status of every line as JSON, one object per line.",
+				Value:       BoolOf(new(bool)),
+			},
+		},
+		Handler: func(inv *Invocation) (err error) {
+			script := inv.Stdin
+			// Commands read an empty stdin when the script comes from
+			// stdin, so that they can't consume its lines.
+			var stdin io.Reader = strings.NewReader("")
+			if len(inv.Args) == 1 && inv.Args[0] != "-" {
+				f, err := os.Open(inv.Args[0])
+				if err != nil {
+					return xerrors.Errorf("open script: %w", err)
+				}
+				defer f.Close()
+				script = f
+				stdin = inv.Stdin
+			}
+
+			d, end := newDispatcher(inv)
+			defer func() {
+				err = errors.Join(err, end())
+			}()
+			opts := inv.Options()
+			b := &batch{
+				dispatcher:      d,
+				stdin:           &lineReader{r: stdin},
+				continueOnError: opts.ByName("continue-on-error").Value.(*Bool).Value(),
+				json:            opts.ByName("json").Value.(*Bool).Value(),
+			}
+			return b.run(script)
+		},
+	}
+}
+
+type batch struct {
+	*dispatcher
+	stdin           *lineReader
+	continueOnError bool
+	json            bool
+}
+
+func (b *batch) run(script io.Reader) error {
+	var (
+		sc     = bufio.NewScanner(script)
+		n      int
+		failed int
+		ran    int
+	)
+	sc.Buffer(nil, maxBatchLineSize)
+	ctx := b.inv.Context()
+	for sc.Scan() {
+		n++
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		ran++
+		err := b.runLine(n, line)
+		if err == nil {
+			continue
+		}
+		if !b.continueOnError {
+			return xerrors.Errorf("line %d: %w", n, err)
+		}
+		failed++
+		if !b.json {
+			_, _ = fmt.Fprintf(b.inv.Stderr, "%s line %d: %v\n", prettyHeader(b.inv.stderrTerminal().ColorProfile(), "error"), n, err)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return xerrors.Errorf("read script: line %d is longer than %d bytes", n+1, maxBatchLineSize)
+		}
+		return xerrors.Errorf("read script: %w", err)
+	}
+	if failed > 0 {
+		return xerrors.Errorf("%d of %d commands failed", failed, ran)
+	}
+	return nil
+}
+
+// runLine runs the command on line n of the script, and reports it if the
+// batch reports JSON.
+func (b *batch) runLine(n int, line string) error {
+	var stdout, stderr bytes.Buffer
+	inv := b.invocation(nil, b.stdin)
+	if b.json {
+		inv.Stdout = &stdout
+		inv.Stderr = &stderr
+	}
+	args, err := splitArgs(line, false)
+	if err == nil {
+		inv.Args = append(inv.Args, args...)
+		err = inv.Run()
+	}
+	if !b.json {
+		return err
+	}
+
+	res := BatchResult{
+		Line:    n,
+		Command: line,
+		Stdout:  stdout.String(),
+		Stderr:  stderr.String(),
+	}
+	if err != nil {
+		res.ExitCode = 1
+		var ec interface{ ExitCode() int }
+		if errors.As(err, &ec) {
+			res.ExitCode = ec.ExitCode()
+		}
+		res.Error = err.Error()
+	}
+	byt, merr := json.Marshal(res)
+	if merr != nil {
+		return errors.Join(err, xerrors.Errorf("marshal result: %w", merr))
+	}
+	_, _ = fmt.Fprintf(b.inv.Stdout, "%s\n", byt)
+	return err
+}
@@ -0,0 +1,195 @@
+package serpent_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	serpent "github.com/coder/serpent"
+	"github.com/coder/serpent/serpenttest"
+)
+
+func batchCommand() *serpent.Command {
+	cmd := replCommand()
+	cmd.Children = append(cmd.Children,
+		&serpent.Command{
+			Use: "exit",
+			Handler: func(inv *serpent.Invocation) error {
+				return exitError(3)
+			},
+		},
+		serpent.BatchCommand(),
+	)
+	return cmd
+}
+
+func TestBatch(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Stdin", func(t *testing.T) {
+		t.Parallel()
+
+		res := serpenttest.Run(t, batchCommand(), serpenttest.RunOptions{
+			Args:  []string{"--upper", "batch"},
+			Stdin: "# A comment.\necho a\n\necho 'b c'\n",
+		})
+		require.NoError(t, res.Err)
+		require.Equal(t, "said: A\nsaid: B C\n", res.Stdout)
+	})
+
+	t.Run("StopOnError", func(t *testing.T) {
+		t.Parallel()
+
+		res := serpenttest.Run(t, batchCommand(), serpenttest.RunOptions{
+			Args:  []string{"batch"},
+			Stdin: "echo a\nfail\necho b\n",
+		})
+		require.ErrorContains(t, res.Err, "line 2: ")
+		require.ErrorContains(t, res.Err, "oops")
+		require.Equal(t, "said: a\n", res.Stdout)
+	})
+
+	t.Run("ContinueOnError", func(t *testing.T) {
+		t.Parallel()
+
+		res := serpenttest.Run(t, batchCommand(), serpenttest.RunOptions{
+			Args:  []string{"batch", "--continue-on-error"},
+			Stdin: "echo a\nfail\necho 'unterminated\necho b\n",
+		})
+		require.ErrorContains(t, res.Err, "2 of 4 commands failed")
+		require.Equal(t, "said: a\nsaid: b\n", res.Stdout)
+		require.Contains(t, res.Stderr, "line 2: ")
+		require.Contains(t, res.Stderr, "line 3: unterminated ' quote")
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		t.Parallel()
+
+		res := serpenttest.Run(t, batchCommand(), serpenttest.RunOptions{
+			Args:  []string{"batch", "--json", "--continue-on-error"},
+			Stdin: "echo a\nexit\n\nfail\n",
+		})
+		require.Error(t, res.Err)
+
+		var results []serpent.BatchResult
+		sc := bufio.NewScanner(strings.NewReader(res.Stdout))
+		for sc.Scan() {
+			var r serpent.BatchResult
+			require.NoError(t, json.Unmarshal(sc.Bytes(), &r), sc.Text())
+			results = append(results, r)
+		}
+		require.Len(t, results, 3)
+		require.Equal(t, serpent.BatchResult{Line: 1, Command: "echo a", Stdout: "said: a\n"}, results[0])
+		require.Equal(t, 2, results[1].Line)
+		require.Equal(t, 3, results[1].ExitCode)
+		require.Equal(t, 4, results[2].Line)
+		require.Equal(t, 1, results[2].ExitCode)
+		require.Contains(t, results[2].Error, "oops")
+	})
+
+	t.Run("OptionsPerLine", func(t *testing.T) {
+		t.Parallel()
+
+		res := serpenttest.Run(t, batchCommand(), serpenttest.RunOptions{
+			Args:  []string{"batch", "--continue-on-error"},
+			Stdin: "echo --upper a\necho b\ngreet --name bob\ngreet\n",
+		})
+		require.ErrorContains(t, res.Err, "1 of 4 commands failed")
+		require.Equal(t, "said: A\nsaid: b\nhello bob\n", res.Stdout)
+		require.Contains(t, res.Stderr, "line 4: Missing values for the required flags: name")
+	})
+
+	t.Run("LongLine", func(t *testing.T) {
+		t.Parallel()
+
+		long := strings.Repeat("x", 100<<10)
+		res := serpenttest.Run(t, batchCommand(), serpenttest.RunOptions{
+			Args:  []string{"batch"},
+			Stdin: "echo " + long + "\necho " + strings.Repeat("y", 2<<20) + "\n",
+		})
+		require.ErrorContains(t, res.Err, "line 2 is longer than 1048576 bytes")
+		require.Equal(t, "said: "+long+"\n", res.Stdout)
+	})
+
+	t.Run("File", func(t *testing.T) {
+		t.Parallel()
+
+		path := filepath.Join(t.TempDir(), "script")
+		require.NoError(t, os.WriteFile(path, []byte("echo a\necho b"), 0o600))
+		res := serpenttest.Run(t, batchCommand(), serpenttest.RunOptions{
+			Args: []string{"batch", path},
+		})
+		require.NoError(t, res.Err)
+		require.Equal(t, "said: a\nsaid: b\n", res.Stdout)
+	})
+}
+
+type closeCounter struct {
+	closed *atomic.Int32
+	url    string
+}
+
+func (c closeCounter) Close() error {
+	c.closed.Add(1)
+	return nil
+}
+
+func TestSessionValue(t *testing.T) {
+	t.Parallel()
+
+	type clientKey struct{}
+	var (
+		dialed, closed atomic.Int32
+		url            string
+		urls           []string
+	)
+	cmd := batchCommand()
+	cmd.Options = append(cmd.Options, serpent.Option{
+		Name:  "url",
+		Flag:  "url",
+		Value: serpent.StringOf(&url),
+	})
+	echo := cmd.Children[0]
+	require.Equal(t, "echo", echo.Name())
+	echo.Middleware = func(next serpent.HandlerFunc) serpent.HandlerFunc {
+		return func(inv *serpent.Invocation) error {
+			// The client is set up from the global flags, read through
+			// their bound variables.
+			client, err := serpent.SessionValue(inv.Context(), clientKey{}, func() (closeCounter, error) {
+				dialed.Add(1)
+				return closeCounter{closed: &closed, url: url}, nil
+			})
+			if err != nil {
+				return err
+			}
+			urls = append(urls, client.url)
+			return next(inv)
+		}
+	}
+
+	res := serpenttest.Run(t, cmd, serpenttest.RunOptions{
+		Args:  []string{"--url", "https://example.com", "batch"},
+		Stdin: "echo a\necho b\necho c\n",
+	})
+	require.NoError(t, res.Err)
+	require.EqualValues(t, 1, dialed.Load())
+	require.EqualValues(t, 1, closed.Load())
+	require.Equal(t, []string{"https://example.com", "https://example.com", "https://example.com"}, urls)
+
+	// Without a session, the value is created every time.
+	for i := 0; i < 2; i++ {
+		_, err := serpent.SessionValue(context.Background(), clientKey{}, func() (int, error) {
+			dialed.Add(1)
+			return 0, nil
+		})
+		require.NoError(t, err)
+	}
+	require.EqualValues(t, 3, dialed.Load())
+}
@@ -9,6 +9,7 @@ import (
 	"os"
 	"os/signal"
 	"strings"
+	"sync"
 	"testing"
 	"unicode"
 
@@ -85,7 +86,7 @@ func (c *Command) AddSubcommands(cmds ...*Command) {
 func (c *Command) Walk(fn func(*Command)) {
 	fn(c)
 	for _, child := range c.Children {
-		child.Parent = c
+		setParent(child, c)
 		child.Walk(fn)
 	}
 }
@@ -99,8 +100,28 @@ func ascendingSortFn[T constraints.Ordered](a, b T) int {
 	return 1
 }
 
+// setParent sets child's Parent, without writing to it if it's already
+// set, so that concurrent invocations of a command tree don't race.
+func setParent(child, parent *Command) {
+	if child.Parent != parent {
+		child.Parent = parent
+	}
+}
+
+// initMu serializes initialization of command trees.
+var initMu sync.Mutex
+
 // init performs initialization and linting on the command and all its children.
+//
+// Once a tree has been initialized, init doesn't write to it again, so
+// that it can be invoked concurrently.
 func (c *Command) init() error {
+	initMu.Lock()
+	defer initMu.Unlock()
+	return c.initLocked()
+}
+
+func (c *Command) initLocked() error {
 	if c.Use == "" {
 		c.Use = "unnamed"
 	}
@@ -129,17 +150,33 @@ func (c *Command) init() error {
 				merr = errors.Join(merr, xerrors.Errorf("option %q description should end with a period", opt.Name))
 			}
 		}
+		switch v := opt.Value.(type) {
+		case *Enum:
+			if err := checkEnumDetails(v.Choices, v.Details); err != nil {
+				merr = errors.Join(merr, xerrors.Errorf("option %q: %w", opt.Name, err))
+			}
+		case *EnumArray:
+			if err := checkEnumDetails(v.Choices, v.Details); err != nil {
+				merr = errors.Join(merr, xerrors.Errorf("option %q: %w", opt.Name, err))
+			}
+		}
 	}
 
-	slices.SortFunc(c.Options, func(a, b Option) int {
+	byName := func(a, b Option) int {
 		return ascendingSortFn(a.Name, b.Name)
-	})
-	slices.SortFunc(c.Children, func(a, b *Command) int {
+	}
+	if !slices.IsSortedFunc(c.Options, byName) {
+		slices.SortFunc(c.Options, byName)
+	}
+	childByName := func(a, b *Command) int {
 		return ascendingSortFn(a.Name(), b.Name())
-	})
+	}
+	if !slices.IsSortedFunc(c.Children, childByName) {
+		slices.SortFunc(c.Children, childByName)
+	}
 	for _, child := range c.Children {
-		child.Parent = c
-		err := child.init()
+		setParent(child, c)
+		err := child.initLocked()
 		if err != nil {
 			merr = errors.Join(merr, xerrors.Errorf("command %v: %w", child.Name(), err))
 		}
@@ -221,6 +258,29 @@ type Invocation struct {
 	// Deprecated
 	Net Net
 
+	// terminal overrides the Terminal detected from Stdout.
+	terminal Terminal
+	// interactiveStdin makes prompts read Stdin even if it isn't a
+	// terminal.
+	interactiveStdin bool
+	// promptMissing makes the invocation prompt for missing required
+	// options.
+	promptMissing bool
+
+	// fileRefStdin is the Stdin read by FileRefs, which is read once per
+	// run.
+	fileRefStdin *fileRefStdin
+	// promptStdin reads the lines of Stdin answering prompts. It's shared
+	// by the copies of the invocation.
+	promptStdin *lineReader
+	// progress tracks the progress reporters started during a run.
+	progress *progressReporters
+
+	// isolated makes the invocation parse into clones of the options,
+	// which are kept in options by command.
+	isolated bool
+	options  map[*Command]OptionSet
+
 	// testing
 	signalNotifyContext func(parent context.Context, signals ...os.Signal) (ctx context.Context, stop context.CancelFunc)
 }
@@ -238,6 +298,103 @@ func (inv *Invocation) WithOS() *Invocation {
 	})
 }
 
+// WithTerminal returns a copy of the invocation whose output is displayed
+// on t, instead of the terminal detected from Stdout.
+func (inv *Invocation) WithTerminal(t Terminal) *Invocation {
+	return inv.with(func(i *Invocation) {
+		i.terminal = t
+	})
+}
+
+// Terminal returns the terminal the invocation's output is displayed on.
+// Unless overridden with WithTerminal, it's detected from Stdout and
+// Environ. Commands should consult it, rather than the process's standard
+// streams, to decide how to format their output.
+func (inv *Invocation) Terminal() Terminal {
+	if inv.terminal != nil {
+		return inv.terminal
+	}
+	return DetectTerminal(inv.Stdout, inv.Environ)
+}
+
+// stderrTerminal returns the terminal Stderr is displayed on, to format
+// what's written to it. It's the terminal set with WithTerminal, if any.
+func (inv *Invocation) stderrTerminal() Terminal {
+	if inv.terminal != nil {
+		return inv.terminal
+	}
+	return DetectTerminal(inv.Stderr, inv.Environ)
+}
+
+// WithIsolatedOptions returns a copy of the invocation that parses into
+// clones of the command tree's option values, rather than into the values
+// the options were declared with. Value sources are recorded on the clones
+// too, so the tree isn't written to and can be invoked concurrently.
+//
+// Handlers must read the parsed values through Options instead of the
+// variables bound to the options: those keep the values they had before
+// the invocation, and aren't safe to read while other invocations run.
+// Every option value in the tree must implement ValueCloner, as all the
+// values in this package do. Run checks them all before parsing anything,
+// and fails if one doesn't.
+func (inv *Invocation) WithIsolatedOptions() *Invocation {
+	return inv.with(func(i *Invocation) {
+		i.isolated = true
+	})
+}
+
+// Options returns the options of the invoked command followed by those of
+// its parents, with the values and sources this invocation parsed. A
+// command's option shadows a parent's option of the same name in ByName.
+//
+// Once Run has started, Command is the subcommand that was invoked.
+func (inv *Invocation) Options() OptionSet {
+	var opts OptionSet
+	for cmd := inv.Command; cmd != nil; cmd = cmd.Parent {
+		opts = append(opts, inv.parsedOptions(cmd)...)
+	}
+	return opts
+}
+
+// parsedOptions returns the options of cmd that the invocation parses into.
+func (inv *Invocation) parsedOptions(cmd *Command) OptionSet {
+	if opts, ok := inv.options[cmd]; ok {
+		return opts
+	}
+	return cmd.Options
+}
+
+// bindOptions returns the options of the current command that the
+// invocation parses into, cloning them if the invocation is isolated.
+func (inv *Invocation) bindOptions() (OptionSet, error) {
+	if !inv.isolated {
+		return inv.Command.Options, nil
+	}
+	opts, err := inv.Command.Options.clone()
+	if err != nil {
+		return nil, xerrors.Errorf("clone options of %q: %w", inv.Command.FullName(), err)
+	}
+	inv.options[inv.Command] = opts
+	return opts, nil
+}
+
+// checkCloneable checks that the option values of cmd and its children can
+// be cloned, so that isolated invocations don't fail halfway through.
+func checkCloneable(cmd *Command) error {
+	var merr error
+	cmd.Walk(func(c *Command) {
+		for _, opt := range c.Options {
+			if opt.Value == nil {
+				continue
+			}
+			if _, ok := opt.Value.(ValueCloner); !ok {
+				merr = errors.Join(merr, xerrors.Errorf("command %q: option %q: value of type %T can't be cloned", c.FullName(), opt.Name, opt.Value))
+			}
+		}
+	})
+	return merr
+}
+
 // WithTestSignalNotifyContext allows overriding the default implementation of SignalNotifyContext.
 // This should only be used in testing.
 func (inv *Invocation) WithTestSignalNotifyContext(
@@ -323,12 +480,20 @@ func (inv *Invocation) CurWords() (prev string, cur string) {
 func (inv *Invocation) run(state *runState) error {
 	if inv.Command.Deprecated != "" {
 		fmt.Fprintf(inv.Stderr, "%s %q is deprecated!. %s\n",
-			prettyHeader("warning"),
+			prettyHeader(inv.stderrTerminal().ColorProfile(), "warning"),
 			inv.Command.FullName(),
 			inv.Command.Deprecated,
 		)
 	}
-	err := inv.Command.Options.ParseEnv(inv.Environ)
+	opts, err := inv.bindOptions()
+	if err != nil {
+		return err
+	}
+	for _, opt := range opts {
+		bindValue(opt.Value, inv)
+	}
+
+	err = opts.ParseEnv(inv.Environ)
 	if err != nil {
 		return xerrors.Errorf("parsing env: %w", err)
 	}
@@ -337,7 +502,6 @@ func (inv *Invocation) run(state *runState) error {
 
 	children := make(map[string]*Command)
 	for _, child := range inv.Command.Children {
-		child.Parent = inv.Command
 		for _, name := range append(child.Aliases, child.Name()) {
 			if _, ok := children[name]; ok {
 				return xerrors.Errorf("duplicate command name: %s", name)
@@ -355,7 +519,7 @@ func (inv *Invocation) run(state *runState) error {
 	// If we find a duplicate flag, we want the deeper command's flag to override
 	// the shallow one. Unfortunately, pflag has no way to remove a flag, so we
 	// have to create a copy of the flagset without a value.
-	inv.Command.Options.FlagSet().VisitAll(func(f *pflag.Flag) {
+	opts.FlagSet().VisitAll(func(f *pflag.Flag) {
 		if inv.parsedFlags.Lookup(f.Name) != nil {
 			inv.parsedFlags = copyFlagSetWithout(inv.parsedFlags, f.Name)
 		}
@@ -372,14 +536,14 @@ func (inv *Invocation) run(state *runState) error {
 	}
 
 	// Set value sources for flags.
-	for i, opt := range inv.Command.Options {
+	for i, opt := range opts {
 		if fl := inv.parsedFlags.Lookup(opt.Flag); fl != nil && fl.Changed {
-			inv.Command.Options[i].ValueSource = ValueSourceFlag
+			opts[i].ValueSource = ValueSourceFlag
 		}
 	}
 
 	// Read YAML configs, if any.
-	for _, opt := range inv.Command.Options {
+	for _, opt := range opts {
 		path, ok := opt.Value.(*YAMLConfigPath)
 		if !ok || path.String() == "" {
 			continue
@@ -396,13 +560,21 @@ func (inv *Invocation) run(state *runState) error {
 			return xerrors.Errorf("decoding yaml: %w", err)
 		}
 
-		err = inv.Command.Options.UnmarshalYAML(&n)
+		for _, o := range opts {
+			walkValue(o.Value, func(v pflag.Value) {
+				if b, ok := v.(configPathBinder); ok {
+					b.bindConfigPath(path.String())
+				}
+			})
+		}
+
+		err = opts.UnmarshalYAML(&n)
 		if err != nil {
 			return xerrors.Errorf("applying yaml: %w", err)
 		}
 	}
 
-	err = inv.Command.Options.SetDefaults()
+	err = opts.SetDefaults()
 	if err != nil {
 		return xerrors.Errorf("setting defaults: %w", err)
 	}
@@ -413,7 +585,6 @@ func (inv *Invocation) run(state *runState) error {
 	if len(parsedArgs) > state.commandDepth {
 		nextArg := parsedArgs[state.commandDepth]
 		if child, ok := children[nextArg]; ok {
-			child.Parent = inv.Command
 			inv.Command = child
 			state.commandDepth++
 			return inv.run(state)
@@ -423,8 +594,20 @@ func (inv *Invocation) run(state *runState) error {
 	// Outputted completions are not filtered based on the word under the cursor, as every shell we support does this already.
 	// We only look at the current word to figure out handler to run, or what directory to inspect.
 	if inv.IsCompletionMode() {
-		for _, e := range inv.complete() {
-			fmt.Fprintln(inv.Stdout, e)
+		completions, hints, directive := splitCompletions(inv.complete())
+		if !inv.completionDirectivesEnabled() {
+			completions = inv.emulateCompletionDirective(completions, directive)
+		}
+		for _, e := range completions {
+			fmt.Fprintln(inv.Stdout, inv.formatCompletion(e))
+		}
+		if inv.completionHintsEnabled() {
+			for _, h := range hints {
+				fmt.Fprintf(inv.Stdout, ":hint:%s\n", h)
+			}
+		}
+		if inv.completionDirectivesEnabled() {
+			fmt.Fprintf(inv.Stdout, ":%d\n", directive)
 		}
 		return nil
 	}
@@ -443,7 +626,7 @@ func (inv *Invocation) run(state *runState) error {
 	// All options should be set. Check all required options have sources,
 	// meaning they were set by the user in some way (env, flag, etc).
 	var missing []string
-	for _, opt := range inv.Command.Options {
+	for _, opt := range opts {
 		if opt.Required && opt.ValueSource == ValueSourceNone {
 			name := opt.Name
 			// use flag as a fallback if name is empty
@@ -453,6 +636,20 @@ func (inv *Invocation) run(state *runState) error {
 			missing = append(missing, name)
 		}
 	}
+	if len(missing) > 0 && inv.promptMissing && inv.IsInteractive() &&
+		!inv.IsCompletionMode() && !errors.Is(state.flagParseErr, pflag.ErrHelp) {
+		for i := range opts {
+			opt := &opts[i]
+			if !opt.Required || opt.ValueSource != ValueSourceNone {
+				continue
+			}
+			err := inv.promptOption(opt)
+			if err != nil {
+				return xerrors.Errorf("prompt for %q: %w", opt.Name, err)
+			}
+		}
+		missing = nil
+	}
 	// Don't error for missing flags if `--help` was supplied.
 	if len(missing) > 0 && !inv.IsCompletionMode() && !errors.Is(state.flagParseErr, pflag.ErrHelp) {
 		return xerrors.Errorf("Missing values for the required flags: %s", strings.Join(missing, ", "))
@@ -487,6 +684,9 @@ func (inv *Invocation) run(state *runState) error {
 
 	ctx, cancel := context.WithCancel(ctx)
 	defer cancel()
+	// Progress reporters left running are stopped before the context is
+	// canceled, which they would report.
+	defer inv.progress.stop()
 	inv = inv.WithContext(ctx)
 
 	if inv.Command.Handler == nil || errors.Is(state.flagParseErr, pflag.ErrHelp) {
@@ -506,6 +706,39 @@ func (inv *Invocation) run(state *runState) error {
 	return nil
 }
 
+// invocationBinder is implemented by values that need access to the
+// invocation they are parsed in, e.g. to read from its Stdin.
+type invocationBinder interface {
+	bindInvocation(inv *Invocation)
+}
+
+// configPathBinder is implemented by values that need to know which YAML
+// config file they are about to be read from.
+type configPathBinder interface {
+	bindConfigPath(path string)
+}
+
+// walkValue calls fn for v and every value it wraps.
+func walkValue(v pflag.Value, fn func(pflag.Value)) {
+	for v != nil {
+		fn(v)
+		u, ok := v.(interface{ Underlying() pflag.Value })
+		if !ok {
+			return
+		}
+		v = u.Underlying()
+	}
+}
+
+// bindValue binds v, and any values it wraps, to inv.
+func bindValue(v pflag.Value, inv *Invocation) {
+	walkValue(v, func(v pflag.Value) {
+		if b, ok := v.(invocationBinder); ok {
+			b.bindInvocation(inv)
+		}
+	})
+}
+
 type RunCommandError struct {
 	Cmd *Command
 	Err error
@@ -567,6 +800,12 @@ func (inv *Invocation) Run() (err error) {
 	if err != nil {
 		return xerrors.Errorf("initializing command: %w", err)
 	}
+	if inv.isolated {
+		err = checkCloneable(inv.Command)
+		if err != nil {
+			return xerrors.Errorf("isolated options: %w", err)
+		}
+	}
 
 	defer func() {
 		// Pflag is panicky, so additional context is helpful in tests.
@@ -591,6 +830,22 @@ func (inv *Invocation) Run() (err error) {
 		e := rc.Close()
 		err = errors.Join(err, e)
 	}()
+	if line, ok := inv.Environ.Lookup(CompletionLineEnv); ok && inv.IsCompletionMode() {
+		// Partial splitting never fails.
+		args, _ := splitArgs(line, true)
+		inv.Args = args[1:]
+	}
+	if inv.isolated {
+		inv.options = make(map[*Command]OptionSet)
+	}
+	inv.fileRefStdin = nil
+	if inv.Stdin != nil {
+		inv.fileRefStdin = &fileRefStdin{r: inv.Stdin}
+	}
+	if inv.promptStdin == nil {
+		inv.promptStdin = &lineReader{r: inv.Stdin}
+	}
+	inv.progress = &progressReporters{}
 	err = inv.run(&runState{
 		allArgs: inv.Args,
 	})
@@ -621,16 +876,27 @@ func (inv *Invocation) complete() []string {
 		// If it's an equals flag
 		if len(flagParts) == 2 {
 			if out := inv.completeFlag(flagName); out != nil {
-				for i, o := range out {
-					out[i] = fmt.Sprintf("--%s=%s", flagName, o)
-				}
-				return out
+				return inv.prefixFlagCompletions(out, "--"+flagName+"=")
 			}
-		} else if out := inv.Command.Options.ByFlag(flagName); out != nil {
+		} else if out := inv.completionOptions().ByFlag(flagName); out != nil {
 			// If the current word is a valid flag, auto-complete it so the
 			// shell moves the cursor
 			return []string{cur}
 		}
+	} else if opt, valueStart, ok := inv.completionOptions().parseShorthands(cur); ok {
+		switch {
+		case opt == nil:
+			// Only boolean shorthands, e.g. "-abc".
+			return []string{cur}
+		case valueStart == len(cur) && !strings.HasSuffix(cur, "="):
+			// The value is in the next word, e.g. "-o".
+			return []string{cur}
+		default:
+			// The value is in this word, e.g. "-ovalue" or "-o=value".
+			if out := inv.completeFlag(opt.Flag); out != nil {
+				return inv.prefixFlagCompletions(out, cur[:valueStart])
+			}
+		}
 	}
 	// If the previous word is a flag, then we're writing it's value
 	// and we should check it's handler
@@ -639,6 +905,11 @@ func (inv *Invocation) complete() []string {
 		if out := inv.completeFlag(word); out != nil {
 			return out
 		}
+	} else if opt, valueStart, ok := inv.completionOptions().parseShorthands(prev); ok &&
+		opt != nil && valueStart == len(prev) && !strings.HasSuffix(prev, "=") {
+		if out := inv.completeFlag(opt.Flag); out != nil {
+			return out
+		}
 	}
 	// If the current word is the command, move the shell cursor
 	if inv.Command.Name() == cur {
@@ -647,7 +918,8 @@ func (inv *Invocation) complete() []string {
 	var completions []string
 
 	if inv.Command.CompletionHandler != nil {
-		completions = append(completions, inv.Command.CompletionHandler(inv)...)
+		out, _ := inv.runCompletionHandler(inv.Command.CompletionHandler)
+		completions = append(completions, out...)
 	}
 
 	completions = append(completions, DefaultCompletionHandler(inv)...)
@@ -655,25 +927,63 @@ func (inv *Invocation) complete() []string {
 	return completions
 }
 
+// prefixFlagCompletions prefixes the completions of a flag value given in
+// the same word as the flag, e.g. "--flag=" or "-f".
+func (inv *Invocation) prefixFlagCompletions(out []string, prefix string) []string {
+	values, hints, directive := splitCompletions(out)
+	if !inv.completionDirectivesEnabled() {
+		// Emulated file names need the flag prefix too.
+		values = inv.emulateCompletionDirective(values, directive)
+		directive = 0
+	}
+	// File filters complete the path natively, and their values are
+	// extensions rather than completions.
+	if directive&(CompletionFilterDirs|CompletionFilterFileExt) == 0 {
+		for i, v := range values {
+			values[i] = prefix + v
+		}
+	}
+	for _, h := range hints {
+		values = append(values, HintCompletion(h))
+	}
+	if directive != 0 {
+		values = append(values, DirectiveCompletion(directive))
+	}
+	return values
+}
+
 func (inv *Invocation) completeFlag(word string) []string {
-	opt := inv.Command.Options.ByFlag(word)
+	opt := inv.completionOptions().ByFlag(word)
 	if opt == nil {
 		return nil
 	}
 	if opt.CompletionHandler != nil {
-		return opt.CompletionHandler(inv)
+		out, _ := inv.runCompletionHandler(opt.CompletionHandler)
+		return out
+	}
+	if c, ok := opt.Value.(ValueCompleter); ok {
+		out, _ := inv.runCompletionHandler(c.CompletionHandler())
+		return out
 	}
 	enum, ok := opt.Value.(*Enum)
 	if ok {
-		return enum.Choices
+		return completeEnumChoices(visibleEnumChoices(enum.Choices, enum.Details))
 	}
 	enumArr, ok := opt.Value.(*EnumArray)
 	if ok {
-		return enumArr.Choices
+		return completeEnumChoices(visibleEnumChoices(enumArr.Choices, enumArr.Details))
 	}
 	return nil
 }
 
+func completeEnumChoices(choices []EnumChoice) []string {
+	out := make([]string, 0, len(choices))
+	for _, c := range choices {
+		out = append(out, DescribeCompletion(c.Value, c.Description))
+	}
+	return out
+}
+
 // MiddlewareFunc returns the next handler in the chain,
 // or nil if there are no more.
 type MiddlewareFunc func(next HandlerFunc) HandlerFunc
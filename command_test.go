@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -674,6 +675,38 @@ func TestCommand_Help(t *testing.T) {
 	})
 }
 
+func TestCommand_HelpEnumChoices(t *testing.T) {
+	t.Parallel()
+
+	var format string
+	cmd := &serpent.Command{
+		Use: "root",
+		Options: serpent.OptionSet{
+			{
+				Name:        "format",
+				Flag:        "format",
+				Description: "Output format.",
+				Value: serpent.EnumOfChoices(&format,
+					serpent.EnumChoice{Value: "table", Description: "Render as a table."},
+					serpent.EnumChoice{Value: "json", Description: "Render as JSON.", Aliases: []string{"js"}},
+					serpent.EnumChoice{Value: "yml", Deprecated: true},
+				),
+			},
+		},
+		Handler: func(i *serpent.Invocation) error { return nil },
+	}
+
+	inv := cmd.Invoke("--help")
+	stdio := fakeIO(inv)
+	require.NoError(t, inv.Run())
+
+	help := stdio.Stdout.String()
+	require.Contains(t, help, "--format table|json")
+	require.Contains(t, help, "table  Render as a table.")
+	require.Contains(t, help, "json   Render as JSON. (aliases: js)")
+	require.Contains(t, help, "yml    DEPRECATED.")
+}
+
 func TestCommand_SliceFlags(t *testing.T) {
 	t.Parallel()
 
@@ -1065,3 +1098,146 @@ func TestCommand_DefaultFn(t *testing.T) {
 		})
 	}
 }
+
+// plainValue is a pflag.Value that doesn't implement serpent.ValueCloner.
+type plainValue struct{ s string }
+
+func (v *plainValue) Set(s string) error { v.s = s; return nil }
+func (v *plainValue) String() string     { return v.s }
+func (*plainValue) Type() string         { return "plain" }
+
+func TestCommand_IsolatedOptions(t *testing.T) {
+	t.Parallel()
+
+	var (
+		verbose bool
+		prefix  string
+		count   int64
+		tags    []string
+	)
+	cmd := &serpent.Command{
+		Use: "root",
+		Options: serpent.OptionSet{
+			{
+				Name:  "verbose",
+				Flag:  "verbose",
+				Value: serpent.BoolOf(&verbose),
+			},
+		},
+		Children: []*serpent.Command{{
+			Use: "echo",
+			Options: serpent.OptionSet{
+				{
+					Name:    "prefix",
+					Flag:    "prefix",
+					Default: "> ",
+					Value:   serpent.StringOf(&prefix),
+				},
+				{
+					Name:  "count",
+					Flag:  "count",
+					Env:   "COUNT",
+					Value: serpent.Int64Of(&count),
+				},
+				{
+					Name:  "tag",
+					Flag:  "tag",
+					Value: serpent.StringArrayOf(&tags),
+				},
+			},
+			Handler: func(inv *serpent.Invocation) error {
+				opts := inv.Options()
+				_, _ = fmt.Fprintf(inv.Stdout, "%s%s count=%s(%s) tags=%s verbose=%s",
+					opts.ByName("prefix").Value,
+					strings.Join(inv.Args, " "),
+					opts.ByName("count").Value,
+					opts.ByName("count").ValueSource,
+					strings.Join(opts.ByName("tag").Value.(*serpent.StringArray).GetSlice(), ","),
+					opts.ByName("verbose").Value,
+				)
+				return nil
+			},
+		}},
+	}
+
+	t.Run("Concurrent", func(t *testing.T) {
+		t.Parallel()
+
+		const n = 20
+		var wg sync.WaitGroup
+		outs := make([]string, n)
+		errs := make([]error, n)
+		for i := 0; i < n; i++ {
+			i := i
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				args := []string{"echo", fmt.Sprintf("msg%d", i), "--tag", fmt.Sprintf("t%d", i)}
+				if i%2 == 0 {
+					args = append(args, "--prefix", fmt.Sprintf("%d: ", i), "--verbose")
+				}
+				inv := cmd.Invoke(args...).WithIsolatedOptions()
+				inv.Environ.Set("COUNT", fmt.Sprint(i))
+				stdio := fakeIO(inv)
+				errs[i] = inv.Run()
+				outs[i] = stdio.Stdout.String()
+			}()
+		}
+		wg.Wait()
+
+		for i := 0; i < n; i++ {
+			require.NoError(t, errs[i])
+			want := fmt.Sprintf("> msg%d count=%d(env) tags=t%d verbose=false", i, i, i)
+			if i%2 == 0 {
+				want = fmt.Sprintf("%d: msg%d count=%d(env) tags=t%d verbose=true", i, i, i, i)
+			}
+			require.Equal(t, want, outs[i])
+		}
+
+		// The tree was left untouched.
+		require.False(t, verbose)
+		require.Empty(t, prefix)
+		require.Zero(t, count)
+		require.Empty(t, tags)
+		for _, opt := range cmd.Children[0].Options {
+			require.Equal(t, serpent.ValueSourceNone, opt.ValueSource, opt.Name)
+		}
+	})
+
+	t.Run("Uncloneable", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{{
+				Name:  "plain",
+				Flag:  "plain",
+				Value: &plainValue{},
+			}},
+			Handler: func(inv *serpent.Invocation) error {
+				return nil
+			},
+		}
+		err := cmd.Invoke().WithIsolatedOptions().Run()
+		require.ErrorContains(t, err, `option "plain": value of type *serpent_test.plainValue can't be cloned`)
+
+		// Values of commands that aren't run are checked too, before
+		// anything is parsed.
+		parent := &serpent.Command{
+			Use:      "parent",
+			Children: []*serpent.Command{cmd},
+			Handler: func(inv *serpent.Invocation) error {
+				return nil
+			},
+		}
+		err = parent.Invoke().WithIsolatedOptions().Run()
+		require.ErrorContains(t, err, `command "parent root": option "plain": value of type *serpent_test.plainValue can't be cloned`)
+		cmd.Parent = nil
+
+		// Without isolation, the value is used as is.
+		err = cmd.Invoke("--plain", "x").Run()
+		require.NoError(t, err)
+		require.Equal(t, "x", cmd.Options[0].Value.String())
+	})
+}
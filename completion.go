@@ -1,8 +1,16 @@
 package serpent
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
 
+	"cdr.dev/slog/v3"
 	"github.com/spf13/pflag"
 )
 
@@ -10,31 +18,335 @@ import (
 // set when the command is being run in completion mode.
 const CompletionModeEnv = "COMPLETION_MODE"
 
+// CompletionDescriptionsEnv is set alongside CompletionModeEnv by shells
+// that can display a description next to each completion. When set, each
+// completion is printed as "value\tdescription".
+const CompletionDescriptionsEnv = "COMPLETION_DESCRIPTIONS"
+
+// CompletionLineEnv may be set alongside CompletionModeEnv by shells that
+// can't pass the words being completed as arguments. It holds the raw
+// command line up to the cursor, including the program name, and takes
+// precedence over the invocation's arguments.
+const CompletionLineEnv = "COMPLETION_LINE"
+
+// CompletionTimeoutEnv may be set alongside CompletionModeEnv to override
+// DefaultCompletionTimeout, as a duration such as "500ms".
+const CompletionTimeoutEnv = "COMPLETION_TIMEOUT"
+
+// DefaultCompletionTimeout is how long completion handlers may run before
+// their context is canceled and the default completions are used instead.
+const DefaultCompletionTimeout = 2 * time.Second
+
+// CompletionDirectivesEnv is set alongside CompletionModeEnv by shell
+// scripts that interpret completion directives. When set, the completions
+// are followed by a final line of the form ":<directive>". Otherwise,
+// directives are emulated as far as possible before printing.
+const CompletionDirectivesEnv = "COMPLETION_DIRECTIVES"
+
+// CompletionDirective tells the shell how to treat the completions returned
+// by a handler. Directives are bit flags and may be combined.
+type CompletionDirective int
+
+const (
+	// CompletionNoSpace stops the shell from adding a space after the
+	// completion, e.g. for "--flag=" or a path prefix.
+	CompletionNoSpace CompletionDirective = 1 << iota
+	// CompletionFileFallback makes the shell complete file names if there
+	// are no other completions. By default, nothing is completed then.
+	CompletionFileFallback
+	// CompletionFilterDirs makes the shell complete directory names only.
+	// Other completions are ignored.
+	CompletionFilterDirs
+	// CompletionFilterFileExt makes the shell complete file names with the
+	// extensions given as completions, e.g. "yaml", and directories.
+	CompletionFilterFileExt
+)
+
+// completionDirectivePrefix marks a completion that carries a directive. A
+// NUL byte can't appear in a command-line argument, so it never collides
+// with a real completion.
+const completionDirectivePrefix = "\x00directive:"
+
+// DirectiveCompletion returns a completion that carries d to the shell
+// instead of a value. Handlers return it alongside their other completions:
+//
+//	return append(exts, serpent.DirectiveCompletion(serpent.CompletionFilterFileExt))
+func DirectiveCompletion(d CompletionDirective) string {
+	return completionDirectivePrefix + strconv.Itoa(int(d))
+}
+
+// CompletionHintsEnv is set alongside CompletionModeEnv by shell scripts
+// that can display hints. Users can set it to "0" to disable hints. Hints
+// are printed as lines of the form ":hint:<message>", before the directive.
+const CompletionHintsEnv = "COMPLETION_HINTS"
+
+// completionHintPrefix marks a completion that carries a hint.
+const completionHintPrefix = "\x00hint:"
+
+// HintCompletion returns a completion that carries a message for the user,
+// such as "a workspace name is required", instead of a value. Shells that
+// support hints display it under the prompt without making it selectable,
+// and other shells drop it. Only the first line of message is used.
+func HintCompletion(message string) string {
+	message, _, _ = strings.Cut(message, "\n")
+	return completionHintPrefix + message
+}
+
+// splitCompletions separates the directives and hints carried by
+// completions from the values. Directives are combined into one.
+func splitCompletions(completions []string) (values []string, hints []string, directive CompletionDirective) {
+	values = make([]string, 0, len(completions))
+	for _, c := range completions {
+		if hint, ok := strings.CutPrefix(c, completionHintPrefix); ok {
+			if !slices.Contains(hints, hint) {
+				hints = append(hints, hint)
+			}
+			continue
+		}
+		d, ok := strings.CutPrefix(c, completionDirectivePrefix)
+		if !ok {
+			values = append(values, c)
+			continue
+		}
+		n, err := strconv.Atoi(d)
+		if err == nil {
+			directive |= CompletionDirective(n)
+		}
+	}
+	return values, hints, directive
+}
+
+// completionHintsEnabled returns whether the shell displays hints, and the
+// user hasn't disabled them.
+func (inv *Invocation) completionHintsEnabled() bool {
+	enabled, err := strconv.ParseBool(inv.Environ.Get(CompletionHintsEnv))
+	return err == nil && enabled
+}
+
+// completionDirectivesEnabled returns whether the shell interprets
+// directives itself.
+func (inv *Invocation) completionDirectivesEnabled() bool {
+	return inv.Environ.Get(CompletionDirectivesEnv) != ""
+}
+
+// emulateCompletionDirective applies d to values for shells that don't
+// interpret directives, by listing the files the shell would have
+// completed. Directives that can't be emulated are ignored.
+func (inv *Invocation) emulateCompletionDirective(values []string, d CompletionDirective) []string {
+	switch {
+	case d&CompletionFilterDirs != 0:
+		return FileCompletionHandler(func(info os.FileInfo) bool {
+			return info.IsDir()
+		})(inv)
+	case d&CompletionFilterFileExt != 0:
+		exts := make([]string, 0, len(values))
+		for _, v := range values {
+			v, _, _ = strings.Cut(v, "\t")
+			exts = append(exts, v)
+		}
+		return FileCompletionHandler(func(info os.FileInfo) bool {
+			return info.IsDir() || hasExtension(info.Name(), exts)
+		})(inv)
+	case d&CompletionFileFallback != 0 && len(values) == 0:
+		return FileCompletionHandler(nil)(inv)
+	}
+	return values
+}
+
+// DescribeCompletion attaches a description to a completion value. The
+// description is only shown by shells that support it, and is dropped
+// otherwise. Only the first line of the description is used.
+func DescribeCompletion(value, description string) string {
+	if description == "" {
+		return value
+	}
+	return value + "\t" + description
+}
+
+// formatCompletion renders a completion returned by a handler for output,
+// keeping its description only if the shell asked for descriptions.
+func (inv *Invocation) formatCompletion(c string) string {
+	value, desc, ok := strings.Cut(c, "\t")
+	if !ok {
+		return c
+	}
+	if inv.Environ.Get(CompletionDescriptionsEnv) == "" {
+		return value
+	}
+	desc, _, _ = strings.Cut(desc, "\n")
+	desc = strings.TrimSpace(strings.ReplaceAll(desc, "\t", " "))
+	if desc == "" {
+		return value
+	}
+	return value + "\t" + desc
+}
+
+// ValueCompleter is implemented by option values that know how to complete
+// their own input. It's used when an Option has no CompletionHandler.
+type ValueCompleter interface {
+	CompletionHandler() CompletionHandlerFunc
+}
+
+func (inv *Invocation) completionTimeout() time.Duration {
+	if v := inv.Environ.Get(CompletionTimeoutEnv); v != "" {
+		d, err := time.ParseDuration(v)
+		if err == nil && d > 0 {
+			return d
+		}
+	}
+	return DefaultCompletionTimeout
+}
+
+// runCompletionHandler calls h with an invocation whose context expires
+// after the completion timeout. It reports false if h didn't return in time
+// or panicked, so the caller can fall back to the default completions
+// instead of hanging the shell or printing an error into it.
+func (inv *Invocation) runCompletionHandler(h CompletionHandlerFunc) ([]string, bool) {
+	ctx, cancel := context.WithTimeout(inv.Context(), inv.completionTimeout())
+	defer cancel()
+
+	type result struct {
+		completions []string
+		ok          bool
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				inv.Logger.Debug(ctx, "completion handler panicked", slog.F("panic", r))
+				done <- result{}
+			}
+		}()
+		done <- result{completions: h(inv.WithContext(ctx)), ok: true}
+	}()
+
+	select {
+	case r := <-done:
+		return r.completions, r.ok
+	case <-ctx.Done():
+		inv.Logger.Debug(ctx, "completion handler timed out", slog.Error(ctx.Err()))
+		return nil, false
+	}
+}
+
 // IsCompletionMode returns true if the command is being run in completion mode.
 func (inv *Invocation) IsCompletionMode() bool {
 	_, ok := inv.Environ.Lookup(CompletionModeEnv)
 	return ok
 }
 
+// completionOptions returns the options whose flags can be given to the
+// command, including those inherited from its parents. As when parsing, a
+// command's flag shadows a parent's flag of the same name.
+func (inv *Invocation) completionOptions() OptionSet {
+	var (
+		opts OptionSet
+		seen = make(map[string]bool)
+	)
+	for cmd := inv.Command; cmd != nil; cmd = cmd.Parent {
+		for _, opt := range inv.parsedOptions(cmd) {
+			if opt.Flag == "" || seen[opt.Flag] {
+				continue
+			}
+			seen[opt.Flag] = true
+			opts = append(opts, opt)
+		}
+	}
+	return opts
+}
+
+// flagExhausted returns whether opt has been set and can't be given again.
+func (inv *Invocation) flagExhausted(opt Option) bool {
+	if _, isSlice := opt.Value.(pflag.SliceValue); isSlice {
+		return false
+	}
+	return (opt.ValueSource != ValueSourceNone && opt.ValueSource != ValueSourceDefault) ||
+		inv.flagChanged(opt)
+}
+
+// flagChanged returns whether opt's flag has been given. Inherited options
+// only have their source recorded by the command that declares them, which
+// may not have parsed the flags given after a subcommand.
+func (inv *Invocation) flagChanged(opt Option) bool {
+	if inv.parsedFlags == nil {
+		return false
+	}
+	fl := inv.parsedFlags.Lookup(opt.Flag)
+	return fl != nil && fl.Changed
+}
+
 // DefaultCompletionHandler is a handler that prints all the subcommands, or
 // all the options that haven't been exhaustively set, if the current word
-// starts with a dash.
+// starts with a dash. Inherited options are included, and hidden ones are
+// left out. Shorthand flags are included if the current word is a single
+// dash. Required flags that haven't been set are hinted at.
 func DefaultCompletionHandler(inv *Invocation) []string {
 	_, cur := inv.CurWords()
 	var allResps []string
+	for _, opt := range inv.completionOptions() {
+		if opt.Required && opt.ValueSource == ValueSourceNone && !inv.flagChanged(opt) {
+			allResps = append(allResps, HintCompletion(fmt.Sprintf("--%s is required", opt.Flag)))
+		}
+	}
 	if strings.HasPrefix(cur, "-") {
-		for _, opt := range inv.Command.Options {
-			_, isSlice := opt.Value.(pflag.SliceValue)
-			if opt.ValueSource == ValueSourceNone ||
-				opt.ValueSource == ValueSourceDefault ||
-				isSlice {
-				allResps = append(allResps, "--"+opt.Flag)
+		for _, opt := range inv.completionOptions() {
+			if opt.Hidden || inv.flagExhausted(opt) {
+				continue
+			}
+			allResps = append(allResps, DescribeCompletion("--"+opt.Flag, opt.Description))
+			if cur == "-" && opt.FlagShorthand != "" {
+				allResps = append(allResps, DescribeCompletion("-"+opt.FlagShorthand, opt.Description))
 			}
 		}
 		return allResps
 	}
 	for _, cmd := range inv.Command.Children {
-		allResps = append(allResps, cmd.Name())
+		allResps = append(allResps, DescribeCompletion(cmd.Name(), cmd.Short))
 	}
 	return allResps
 }
+
+// FileCompletionHandler returns a handler that completes file names, using
+// the given filter func, which may be nil.
+func FileCompletionHandler(filter func(info os.FileInfo) bool) CompletionHandlerFunc {
+	return func(inv *Invocation) []string {
+		var out []string
+		_, word := inv.CurWords()
+
+		dir, _ := filepath.Split(word)
+		if dir == "" {
+			dir = "."
+		}
+		f, err := os.Open(dir)
+		if err != nil {
+			return out
+		}
+		defer f.Close()
+		if dir == "." {
+			dir = ""
+		}
+
+		infos, err := f.Readdir(0)
+		if err != nil {
+			return out
+		}
+
+		for _, info := range infos {
+			if filter != nil && !filter(info) {
+				continue
+			}
+
+			var cur string
+			if info.IsDir() {
+				cur = fmt.Sprintf("%s%s%c", dir, info.Name(), os.PathSeparator)
+			} else {
+				cur = fmt.Sprintf("%s%s", dir, info.Name())
+			}
+
+			if strings.HasPrefix(cur, word) {
+				out = append(out, cur)
+			}
+		}
+		return out
+	}
+}
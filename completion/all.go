@@ -35,6 +35,9 @@ const (
 	ShellFish       string = "fish"
 	ShellZsh        string = "zsh"
 	ShellPowershell string = "powershell"
+	ShellNushell    string = "nushell"
+	ShellElvish     string = "elvish"
+	ShellTcsh       string = "tcsh"
 )
 
 func ShellByName(shell, programName string) (Shell, error) {
@@ -47,16 +50,29 @@ func ShellByName(shell, programName string) (Shell, error) {
 		return Zsh(runtime.GOOS, programName), nil
 	case ShellPowershell:
 		return Powershell(runtime.GOOS, programName), nil
+	// The nushell binary is named "nu", which is what $SHELL holds.
+	case ShellNushell, "nu":
+		return Nushell(runtime.GOOS, programName), nil
+	case ShellElvish:
+		return Elvish(runtime.GOOS, programName), nil
+	case ShellTcsh:
+		return Tcsh(runtime.GOOS, programName), nil
 	default:
 		return nil, fmt.Errorf("unsupported shell %q", shell)
 	}
 }
 
 func ShellOptions(choice *string) *serpent.Enum {
-	return serpent.EnumOf(choice, ShellBash, ShellFish, ShellZsh, ShellPowershell)
+	return serpent.EnumOf(choice, ShellBash, ShellFish, ShellZsh, ShellPowershell, ShellNushell, ShellElvish, ShellTcsh)
 }
 
 func DetectUserShell(programName string) (Shell, error) {
+	// Nushell is rarely a login shell, so $SHELL tends to point elsewhere
+	// even when the user is running it.
+	if os.Getenv("NU_VERSION") != "" {
+		return ShellByName(ShellNushell, programName)
+	}
+
 	// Attempt to get the SHELL environment variable first
 	if shell := os.Getenv("SHELL"); shell != "" {
 		return ShellByName(filepath.Base(shell), programName)
@@ -110,34 +126,77 @@ func writeConfig(
 	return nil
 }
 
-func InstallShellCompletion(shell Shell) error {
-	path, err := shell.InstallPath()
-	if err != nil {
-		return fmt.Errorf("get install path: %w", err)
-	}
+// InstallStatus describes whether a shell's completion script is
+// installed.
+type InstallStatus string
+
+const (
+	// StatusAbsent means no completion script is installed.
+	StatusAbsent InstallStatus = "absent"
+	// StatusInstalled means the installed completion script is current.
+	StatusInstalled InstallStatus = "installed"
+	// StatusOutdated means a completion script is installed, but differs
+	// from the one the program would install now.
+	StatusOutdated InstallStatus = "outdated"
+)
+
+// completionBlock returns the header and footer that mark the completion
+// script in the shell's install file, and the full block including the
+// script.
+func completionBlock(shell Shell) (header, footer, block []byte, err error) {
 	var headerBuf bytes.Buffer
 	err = writeConfig(&headerBuf, completionStartTemplate, shell.ProgramName())
 	if err != nil {
-		return fmt.Errorf("generate header: %w", err)
+		return nil, nil, nil, fmt.Errorf("generate header: %w", err)
 	}
 
-	var footerBytes bytes.Buffer
-	err = writeConfig(&footerBytes, completionEndTemplate, shell.ProgramName())
+	var footerBuf bytes.Buffer
+	err = writeConfig(&footerBuf, completionEndTemplate, shell.ProgramName())
 	if err != nil {
-		return fmt.Errorf("generate footer: %w", err)
+		return nil, nil, nil, fmt.Errorf("generate footer: %w", err)
 	}
 
-	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	var blockBuf bytes.Buffer
+	_, _ = blockBuf.Write(headerBuf.Bytes())
+	err = shell.WriteCompletion(&blockBuf)
 	if err != nil {
-		return fmt.Errorf("create directories: %w", err)
+		return nil, nil, nil, fmt.Errorf("generate completion: %w", err)
 	}
+	_, _ = blockBuf.Write(footerBuf.Bytes())
+	return headerBuf.Bytes(), footerBuf.Bytes(), blockBuf.Bytes(), nil
+}
 
-	f, err := os.ReadFile(path)
+// readInstallFile reads the shell's install file, treating a missing file
+// as empty.
+func readInstallFile(shell Shell) (path string, data []byte, err error) {
+	path, err = shell.InstallPath()
+	if err != nil {
+		return "", nil, fmt.Errorf("get install path: %w", err)
+	}
+	data, err = os.ReadFile(path)
 	if err != nil && !errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("read ssh config failed: %w", err)
+		return "", nil, fmt.Errorf("read config failed: %w", err)
 	}
+	return path, data, nil
+}
 
-	before, after, err := templateConfigSplit(headerBuf.Bytes(), footerBytes.Bytes(), f)
+func InstallShellCompletion(shell Shell) error {
+	header, footer, block, err := completionBlock(shell)
+	if err != nil {
+		return err
+	}
+
+	path, f, err := readInstallFile(shell)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return fmt.Errorf("create directories: %w", err)
+	}
+
+	before, after, err := templateConfigSplit(header, footer, f)
 	if err != nil {
 		return err
 	}
@@ -147,12 +206,7 @@ func InstallShellCompletion(shell Shell) error {
 	if len(before) > 0 {
 		_, _ = outBuf.Write([]byte("\n"))
 	}
-	_, _ = outBuf.Write(headerBuf.Bytes())
-	err = shell.WriteCompletion(&outBuf)
-	if err != nil {
-		return fmt.Errorf("generate completion: %w", err)
-	}
-	_, _ = outBuf.Write(footerBytes.Bytes())
+	_, _ = outBuf.Write(block)
 	_, _ = outBuf.Write([]byte("\n"))
 	_, _ = outBuf.Write(after)
 
@@ -164,6 +218,72 @@ func InstallShellCompletion(shell Shell) error {
 	return nil
 }
 
+// UninstallShellCompletion removes the completion script installed by
+// InstallShellCompletion, leaving the rest of the file untouched. It does
+// nothing if the script isn't installed.
+func UninstallShellCompletion(shell Shell) error {
+	header, footer, _, err := completionBlock(shell)
+	if err != nil {
+		return err
+	}
+
+	path, f, err := readInstallFile(shell)
+	if err != nil {
+		return err
+	}
+	if !bytes.Contains(f, header) && !bytes.Contains(f, footer) {
+		return nil
+	}
+
+	before, after, err := templateConfigSplit(header, footer, f)
+	if err != nil {
+		return err
+	}
+
+	outBuf := bytes.Buffer{}
+	_, _ = outBuf.Write(before)
+	if len(before) > 0 && len(after) > 0 {
+		// Installing consumed the newline that separated the two.
+		_, _ = outBuf.Write([]byte("\n"))
+	}
+	_, _ = outBuf.Write(after)
+
+	err = atomic.WriteFile(path, &outBuf)
+	if err != nil {
+		return fmt.Errorf("write config: %w", err)
+	}
+	return nil
+}
+
+// ShellCompletionStatus reports whether the shell's completion script is
+// installed, and whether it matches the script that would be installed now.
+func ShellCompletionStatus(shell Shell) (InstallStatus, error) {
+	header, footer, block, err := completionBlock(shell)
+	if err != nil {
+		return "", err
+	}
+
+	_, f, err := readInstallFile(shell)
+	if err != nil {
+		return "", err
+	}
+
+	before, after, err := templateConfigSplit(header, footer, f)
+	if err != nil {
+		return "", err
+	}
+	if len(before) == len(f) {
+		return StatusAbsent, nil
+	}
+
+	start := bytes.Index(f, header)
+	end := len(f) - len(after)
+	if !bytes.Equal(bytes.TrimRight(f[start:end], "\n"), block) {
+		return StatusOutdated, nil
+	}
+	return StatusInstalled, nil
+}
+
 func templateConfigSplit(header, footer, data []byte) (before, after []byte, err error) {
 	startCount := bytes.Count(data, header)
 	endCount := bytes.Count(data, footer)
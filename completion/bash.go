@@ -46,16 +46,61 @@ _generate_{{.Name}}_completions() {
     local args=("${COMP_WORDS[@]:1:COMP_CWORD}")
 
     declare -a output
-    mapfile -t output < <(COMPLETION_MODE=1 "{{.Name}}" "${args[@]}")
+    mapfile -t output < <(COMPLETION_MODE=1 COMPLETION_DIRECTIVES=1 COMPLETION_HINTS="${COMPLETION_HINTS:-1}" "{{.Name}}" "${args[@]}")
+
+    # The last line is the directive, a bit set of: 1 no space, 2 file
+    # fallback, 4 directories only, 8 file extension filter.
+    local directive=0
+    if [[ ${#output[@]} -gt 0 && ${output[-1]} =~ ^:([0-9]+)$ ]]; then
+        directive=${BASH_REMATCH[1]}
+        unset 'output[-1]'
+    fi
+
+    # Hints are shown as the only candidates when there are no others.
+    local -a hints=()
+    local i
+    for i in "${!output[@]}"; do
+        if [[ ${output[i]} == :hint:* ]]; then
+            hints+=("${output[i]#:hint:}")
+            unset 'output[i]'
+        fi
+    done
+    output=("${output[@]}")
+
+    COMPREPLY=()
+    if (( directive & 4 )); then
+        compopt -o filenames 2>/dev/null
+        mapfile -t COMPREPLY < <(compgen -d -- "$2")
+        return
+    fi
+    if (( directive & 8 )); then
+        compopt -o filenames 2>/dev/null
+        local ext
+        mapfile -t COMPREPLY < <(compgen -d -- "$2")
+        for ext in "${output[@]}"; do
+            mapfile -t -O "${#COMPREPLY[@]}" COMPREPLY < <(compgen -f -X "!*.$ext" -- "$2")
+        done
+        return
+    fi
+    if (( directive & 1 )); then
+        compopt -o nospace 2>/dev/null
+    fi
 
     declare -a completions
-    mapfile -t completions < <( compgen -W "$(printf '%q ' "${output[@]}")" -- "$2" )
+    if (( ${#output[@]} > 0 )); then
+        mapfile -t completions < <( compgen -W "$(printf '%q ' "${output[@]}")" -- "$2" )
+    fi
 
     local comp
-    COMPREPLY=()
     for comp in "${completions[@]}"; do
         COMPREPLY+=("$(printf "%q" "$comp")")
     done
+    if (( ${#COMPREPLY[@]} == 0 && directive & 2 )); then
+        compopt -o default 2>/dev/null
+    elif (( ${#COMPREPLY[@]} == 0 && ${#hints[@]} > 0 )); then
+        # The empty candidate stops bash from inserting a lone hint.
+        COMPREPLY=("${hints[@]}" "")
+    fi
 }
 # Setup Bash to use the function for completions for '{{.Name}}'
 complete -F _generate_{{.Name}}_completions {{.Name}}
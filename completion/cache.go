@@ -0,0 +1,106 @@
+package completion
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/natefinch/atomic"
+
+	"github.com/coder/serpent"
+)
+
+// Cache stores the completions of slow handlers on disk, so that pressing
+// TAB repeatedly on the same command line is instant.
+type Cache struct {
+	// Dir is where cached completions are stored. If empty, a "completion"
+	// directory under the program's directory in os.UserCacheDir is used.
+	Dir string
+	// TTL is how long cached completions are used for. If zero or less,
+	// nothing is cached.
+	TTL time.Duration
+}
+
+type cacheEntry struct {
+	ExpiresAt   time.Time `json:"expires_at"`
+	Completions []string  `json:"completions"`
+}
+
+// CachedHandler wraps h with a Cache in the default directory.
+func CachedHandler(ttl time.Duration, h serpent.CompletionHandlerFunc) serpent.CompletionHandlerFunc {
+	return Cache{TTL: ttl}.Handler(h)
+}
+
+// Handler wraps h so that its completions are cached. Entries are keyed by
+// the command path, the arguments before the word being completed and, for
+// "--flag=value", the flag. Empty results, and results from a handler whose
+// context expired, are not cached.
+func (c Cache) Handler(h serpent.CompletionHandlerFunc) serpent.CompletionHandlerFunc {
+	return func(inv *serpent.Invocation) []string {
+		if c.TTL <= 0 {
+			return h(inv)
+		}
+		path, ok := c.path(inv)
+		if !ok {
+			return h(inv)
+		}
+		if completions, ok := readCacheEntry(path); ok {
+			return completions
+		}
+
+		completions := h(inv)
+		if len(completions) == 0 || inv.Context().Err() != nil {
+			return completions
+		}
+		byt, err := json.Marshal(cacheEntry{
+			ExpiresAt:   time.Now().Add(c.TTL),
+			Completions: completions,
+		})
+		if err == nil && os.MkdirAll(filepath.Dir(path), 0o700) == nil {
+			// A failed write only costs the next completion its speed.
+			_ = atomic.WriteFile(path, bytes.NewReader(byt))
+		}
+		return completions
+	}
+}
+
+// path returns the file the completions of inv are cached in.
+func (c Cache) path(inv *serpent.Invocation) (string, bool) {
+	dir := c.Dir
+	if dir == "" {
+		cacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return "", false
+		}
+		dir = filepath.Join(cacheDir, rootCommand(inv.Command).Name(), "completion")
+	}
+
+	key := []string{inv.Command.FullName()}
+	if len(inv.Args) > 0 {
+		key = append(key, inv.Args[:len(inv.Args)-1]...)
+	}
+	_, cur := inv.CurWords()
+	if flag, _, ok := strings.Cut(cur, "="); ok && strings.HasPrefix(flag, "-") {
+		key = append(key, flag)
+	}
+	sum := sha256.Sum256([]byte(strings.Join(key, "\x00")))
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), true
+}
+
+func readCacheEntry(path string) ([]string, bool) {
+	byt, err := os.ReadFile(path)
+	if err != nil {
+		return nil, false
+	}
+	var entry cacheEntry
+	err = json.Unmarshal(byt, &entry)
+	if err != nil || time.Now().After(entry.ExpiresAt) {
+		return nil, false
+	}
+	return entry.Completions, true
+}
@@ -0,0 +1,122 @@
+package completion
+
+import (
+	"fmt"
+
+	"github.com/coder/serpent"
+)
+
+// Command returns a "completion" command that manages the completion script
+// of the root command it is added to. It has install, uninstall, status and
+// print subcommands, and prints the script when run on its own. The shell is
+// chosen with --shell, or detected from the user's environment.
+//
+// It reads --shell through the invocation's options, so it can be invoked
+// with WithIsolatedOptions.
+func Command() *serpent.Command {
+	var shellName string
+	shell := func(inv *serpent.Invocation) (Shell, error) {
+		programName := rootCommand(inv.Command).Name()
+		if name := inv.Options().ByFlag("shell").Value.String(); name != "" {
+			return ShellByName(name, programName)
+		}
+		s, err := DetectUserShell(programName)
+		if err != nil {
+			return nil, fmt.Errorf("could not detect user shell, please specify a shell using `--shell`: %w", err)
+		}
+		return s, nil
+	}
+	printScript := func(inv *serpent.Invocation) error {
+		s, err := shell(inv)
+		if err != nil {
+			return err
+		}
+		return s.WriteCompletion(inv.Stdout)
+	}
+
+	return &serpent.Command{
+		Use:   "completion [--shell <shell>]",
+		Short: "Manage the completion script for your shell.",
+		Options: serpent.OptionSet{
+			{
+				Flag:          "shell",
+				FlagShorthand: "s",
+				Description:   "The shell to manage completion for. Defaults to the current shell.",
+				Value:         ShellOptions(&shellName),
+			},
+		},
+		Handler: printScript,
+		Children: []*serpent.Command{
+			{
+				Use:   "install",
+				Short: "Install the completion script into your shell's configuration.",
+				Handler: func(inv *serpent.Invocation) error {
+					s, err := shell(inv)
+					if err != nil {
+						return err
+					}
+					err = InstallShellCompletion(s)
+					if err != nil {
+						return fmt.Errorf("install %s completion: %w", s.Name(), err)
+					}
+					path, _ := s.InstallPath()
+					_, _ = fmt.Fprintf(inv.Stdout, "Installed %s completion for %s in %s\n", s.Name(), s.ProgramName(), path)
+					return nil
+				},
+			},
+			{
+				Use:   "uninstall",
+				Short: "Remove the installed completion script from your shell's configuration.",
+				Handler: func(inv *serpent.Invocation) error {
+					s, err := shell(inv)
+					if err != nil {
+						return err
+					}
+					err = UninstallShellCompletion(s)
+					if err != nil {
+						return fmt.Errorf("uninstall %s completion: %w", s.Name(), err)
+					}
+					path, _ := s.InstallPath()
+					_, _ = fmt.Fprintf(inv.Stdout, "Removed %s completion for %s from %s\n", s.Name(), s.ProgramName(), path)
+					return nil
+				},
+			},
+			{
+				Use:   "status",
+				Short: "Show whether the completion script is installed and up to date.",
+				Handler: func(inv *serpent.Invocation) error {
+					s, err := shell(inv)
+					if err != nil {
+						return err
+					}
+					status, err := ShellCompletionStatus(s)
+					if err != nil {
+						return fmt.Errorf("check %s completion: %w", s.Name(), err)
+					}
+					path, _ := s.InstallPath()
+					switch status {
+					case StatusAbsent:
+						_, _ = fmt.Fprintf(inv.Stdout, "%s completion for %s is not installed in %s\n", s.Name(), s.ProgramName(), path)
+					case StatusOutdated:
+						_, _ = fmt.Fprintf(inv.Stdout, "%s completion for %s in %s is outdated, run install to update it\n", s.Name(), s.ProgramName(), path)
+					default:
+						_, _ = fmt.Fprintf(inv.Stdout, "%s completion for %s is installed in %s\n", s.Name(), s.ProgramName(), path)
+					}
+					return nil
+				},
+			},
+			{
+				Use:     "print",
+				Short:   "Print the completion script to stdout.",
+				Handler: printScript,
+			},
+		},
+	}
+}
+
+func rootCommand(cmd *serpent.Command) *serpent.Command {
+	for cmd.Parent != nil {
+		cmd = cmd.Parent
+	}
+	return cmd
+}
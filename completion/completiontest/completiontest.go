@@ -0,0 +1,282 @@
+// Package completiontest helps test the shell completions of serpent
+// commands.
+//
+// Complete requests completions from a command in-process, the same way the
+// completion scripts do. ShellComplete runs the generated completion script
+// under a shell against a built binary, and is skipped if the shell isn't
+// installed.
+package completiontest
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/coder/serpent"
+	"github.com/coder/serpent/completion"
+)
+
+// Completion is a single completion candidate.
+type Completion struct {
+	Value       string
+	Description string
+}
+
+// Result is the response to a completion request.
+type Result struct {
+	Completions []Completion
+	Hints       []string
+	Directive   serpent.CompletionDirective
+}
+
+// Values returns the values of the completions, without descriptions.
+func (r Result) Values() []string {
+	values := make([]string, 0, len(r.Completions))
+	for _, c := range r.Completions {
+		values = append(values, c.Value)
+	}
+	return values
+}
+
+// Complete requests completions from root as if the user pressed TAB at the
+// end of line. The line includes the program name, is split following POSIX
+// shell quoting rules, and a trailing space starts a new, empty word.
+// Descriptions, hints and directives are requested, as zsh does.
+//
+// Running a command sets its option values, so root should be freshly
+// built for every call.
+func Complete(t testing.TB, root *serpent.Command, line string) Result {
+	t.Helper()
+	return CompleteAt(t, root, line, len(line))
+}
+
+// CompleteAt is like Complete, with the cursor at the given byte offset in
+// line. Everything after the cursor is ignored, as shells do.
+func CompleteAt(t testing.TB, root *serpent.Command, line string, cursor int) Result {
+	t.Helper()
+	if cursor < 0 || cursor > len(line) {
+		t.Fatalf("cursor %d out of range for line %q", cursor, line)
+	}
+
+	var stdout, stderr bytes.Buffer
+	inv := root.Invoke()
+	inv.Stdout = &stdout
+	inv.Stderr = &stderr
+	inv.Environ.Set(serpent.CompletionModeEnv, "1")
+	inv.Environ.Set(serpent.CompletionDescriptionsEnv, "1")
+	inv.Environ.Set(serpent.CompletionDirectivesEnv, "1")
+	inv.Environ.Set(serpent.CompletionHintsEnv, "1")
+	inv.Environ.Set(serpent.CompletionLineEnv, line[:cursor])
+	err := inv.Run()
+	if err != nil {
+		t.Fatalf("complete %q: %v\nstderr: %s", line[:cursor], err, stderr.String())
+	}
+	return parseOutput(t, stdout.String())
+}
+
+// parseOutput parses the output of a command run with descriptions, hints
+// and directives enabled.
+func parseOutput(t testing.TB, out string) Result {
+	t.Helper()
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	last := lines[len(lines)-1]
+	d, err := strconv.Atoi(strings.TrimPrefix(last, ":"))
+	if !strings.HasPrefix(last, ":") || err != nil {
+		t.Fatalf("completion output has no directive: %q", out)
+	}
+
+	r := Result{Directive: serpent.CompletionDirective(d)}
+	for _, line := range lines[:len(lines)-1] {
+		if hint, ok := strings.CutPrefix(line, ":hint:"); ok {
+			r.Hints = append(r.Hints, hint)
+			continue
+		}
+		value, desc, _ := strings.Cut(line, "\t")
+		r.Completions = append(r.Completions, Completion{Value: value, Description: desc})
+	}
+	return r
+}
+
+// ZshStubbed is the shell to pass to ShellComplete for zsh. The zsh
+// completion system can't run without a terminal, so the script runs under
+// zsh with its functions, such as _describe and compadd, replaced by stubs
+// that print the candidates. It tests the script's logic, but not how zsh
+// displays the candidates.
+const ZshStubbed = "zsh-stubbed"
+
+// ShellComplete runs the completion script for shell ("bash", "fish" or
+// ZshStubbed) under that shell, as if the user pressed TAB at the end of
+// line, and returns the candidates the shell would offer. The first word of
+// line is the program name, and bin is the path of a built binary to run as
+// that program. The test is skipped if the shell isn't installed.
+//
+// Bash words are split as by eval rather than by COMP_WORDBREAKS.
+func ShellComplete(t testing.TB, shell string, bin string, line string) []Completion {
+	t.Helper()
+
+	driver, ok := shellDrivers[shell]
+	if !ok {
+		if shell == completion.ShellZsh {
+			t.Fatalf("unsupported shell %q, use ZshStubbed", shell)
+		}
+		t.Fatalf("unsupported shell %q", shell)
+	}
+	shell = driver.shell
+	shellPath, err := exec.LookPath(shell)
+	if err != nil {
+		t.Skipf("%s is not installed", shell)
+	}
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		t.Fatalf("line %q has no program name", line)
+	}
+	name := fields[0]
+
+	bin, err = filepath.Abs(bin)
+	if err != nil {
+		t.Fatalf("resolve binary: %v", err)
+	}
+	dir := t.TempDir()
+	err = os.Symlink(bin, filepath.Join(dir, name))
+	if err != nil {
+		t.Fatalf("link binary: %v", err)
+	}
+
+	sh, err := completion.ShellByName(shell, name)
+	if err != nil {
+		t.Fatalf("get shell: %v", err)
+	}
+	var script bytes.Buffer
+	err = sh.WriteCompletion(&script)
+	if err != nil {
+		t.Fatalf("write completion script: %v", err)
+	}
+	scriptPath := filepath.Join(dir, "completion."+shell)
+	err = os.WriteFile(scriptPath, script.Bytes(), 0o600)
+	if err != nil {
+		t.Fatalf("write completion script: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	//nolint:gosec // The shell and its arguments are controlled by the test.
+	cmd := exec.Command(shellPath, append(driver.args, driver.script)...)
+	cmd.Env = append(os.Environ(),
+		"PATH="+dir+string(os.PathListSeparator)+os.Getenv("PATH"),
+		"COMPLETIONTEST_SCRIPT="+scriptPath,
+		"COMPLETIONTEST_LINE="+line,
+		"COMPLETIONTEST_NAME="+name,
+	)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	err = cmd.Run()
+	if err != nil {
+		t.Fatalf("run %s: %v\nstderr: %s", shell, err, stderr.String())
+	}
+
+	var completions []Completion
+	for _, l := range strings.Split(stdout.String(), "\n") {
+		if l == "" {
+			continue
+		}
+		completions = append(completions, driver.parse(l))
+	}
+	return completions
+}
+
+// shellDriver sources a completion script and prints the candidates for a
+// command line. The script reads the path of the completion script, the
+// line and the program name from the COMPLETIONTEST_SCRIPT,
+// COMPLETIONTEST_LINE and COMPLETIONTEST_NAME environment variables.
+type shellDriver struct {
+	// shell is the name of the shell to run.
+	shell  string
+	args   []string
+	script string
+	parse  func(line string) Completion
+}
+
+var shellDrivers = map[string]shellDriver{
+	completion.ShellBash: {
+		shell: completion.ShellBash,
+		args:  []string{"--norc", "--noprofile", "-c"},
+		script: `source "$COMPLETIONTEST_SCRIPT"
+line=$COMPLETIONTEST_LINE
+fn=$(complete -p "$COMPLETIONTEST_NAME" | sed -E 's/.*-F ([^ ]+).*/\1/')
+eval "COMP_WORDS=($line)"
+if [[ $line == *[[:space:]] ]]; then
+    COMP_WORDS+=("")
+fi
+COMP_CWORD=$((${#COMP_WORDS[@]} - 1))
+COMP_LINE=$line
+COMP_POINT=${#line}
+"$fn" "$COMPLETIONTEST_NAME" "${COMP_WORDS[COMP_CWORD]}" "${COMP_WORDS[COMP_CWORD-1]}"
+if [[ ${#COMPREPLY[@]} -gt 0 ]]; then
+    printf '%s\n' "${COMPREPLY[@]}"
+fi`,
+		parse: func(line string) Completion {
+			return Completion{Value: line}
+		},
+	},
+	ZshStubbed: {
+		shell: completion.ShellZsh,
+		args:  []string{"-f", "-c"},
+		script: `compdef() { _completiontest_fn=$1; }
+compset() { return 0; }
+_message() { :; }
+_describe() {
+	local name=$4
+	print -rl -- "${(@P)name}"
+}
+_files() {
+	local f
+	for f in *(N/); do print -r -- "$f/"; done
+	if [[ $1 == -g ]]; then
+		print -rl -- ${~2}(N.)
+	else
+		print -rl -- *(N.)
+	fi
+}
+_path_files() {
+	local f
+	for f in *(N/); do print -r -- "$f/"; done
+}
+source "$COMPLETIONTEST_SCRIPT"
+words=(${(z)COMPLETIONTEST_LINE})
+if [[ $COMPLETIONTEST_LINE == *' ' ]]; then
+	words+=('')
+fi
+CURRENT=${#words}
+$_completiontest_fn`,
+		parse: func(line string) Completion {
+			// _describe takes "value:description", with colons in the
+			// value escaped.
+			var value strings.Builder
+			for i := 0; i < len(line); i++ {
+				switch {
+				case line[i] == '\\' && i+1 < len(line) && line[i+1] == ':':
+					_ = value.WriteByte(':')
+					i++
+				case line[i] == ':':
+					return Completion{Value: value.String(), Description: line[i+1:]}
+				default:
+					_ = value.WriteByte(line[i])
+				}
+			}
+			return Completion{Value: value.String()}
+		},
+	},
+	completion.ShellFish: {
+		shell: completion.ShellFish,
+		args:  []string{"--no-config", "-c"},
+		script: `source $COMPLETIONTEST_SCRIPT
+complete -C $COMPLETIONTEST_LINE`,
+		parse: func(line string) Completion {
+			value, desc, _ := strings.Cut(line, "\t")
+			return Completion{Value: value, Description: desc}
+		},
+	},
+}
@@ -0,0 +1,71 @@
+package completion
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	home "github.com/mitchellh/go-homedir"
+)
+
+type elvish struct {
+	goos        string
+	programName string
+}
+
+var _ Shell = &elvish{}
+
+func Elvish(goos string, programName string) Shell {
+	return &elvish{goos: goos, programName: programName}
+}
+
+func (e *elvish) Name() string {
+	return "elvish"
+}
+
+func (e *elvish) InstallPath() (string, error) {
+	homeDir, err := home.Dir()
+	if err != nil {
+		return "", err
+	}
+	// Older versions of elvish only read ~/.elvish/rc.elv, so keep using it
+	// if it's there.
+	legacy := filepath.Join(homeDir, ".elvish", "rc.elv")
+	if _, err := os.Stat(legacy); err == nil {
+		return legacy, nil
+	}
+	if e.goos == "windows" {
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "elvish", "rc.elv"), nil
+		}
+		return filepath.Join(homeDir, "AppData", "Roaming", "elvish", "rc.elv"), nil
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "elvish", "rc.elv"), nil
+	}
+	return filepath.Join(homeDir, ".config", "elvish", "rc.elv"), nil
+}
+
+func (e *elvish) WriteCompletion(w io.Writer) error {
+	return writeConfig(w, elvishCompletionTemplate, e.programName)
+}
+
+func (e *elvish) ProgramName() string {
+	return e.programName
+}
+
+const elvishCompletionTemplate = `
+use str
+set edit:completion:arg-completer[{{.Name}}] = {|@words|
+    tmp E:COMPLETION_MODE = 1
+    tmp E:COMPLETION_DESCRIPTIONS = 1
+    {{.Name}} (all $words[1..]) | from-lines | each {|line|
+        var parts = [(str:split "\t" $line)]
+        if (> (count $parts) 1) {
+            edit:complex-candidate $parts[0] &display=$parts[0]' ('$parts[1]')'
+        } else {
+            put $line
+        }
+    }
+}
+`
@@ -43,7 +43,31 @@ function _{{.Name}}_completions
 	# Capture the full command line as an array
 	set -l args (commandline -opc)
 	set -l current (commandline -ct)
-    COMPLETION_MODE=1 $args $current
+	# Fish natively understands "value<TAB>description" lines.
+	set -l lines (COMPLETION_MODE=1 COMPLETION_DESCRIPTIONS=1 COMPLETION_DIRECTIVES=1 $args $current)
+	# The last line is the directive, a bit set of: 1 no space, 2 file
+	# fallback, 4 directories only, 8 file extension filter. Fish already
+	# leaves out the space after "=" and "/", so no space isn't handled.
+	set -l directive 0
+	if set -q lines[1]; and string match -qr '^:[0-9]+$' -- $lines[-1]
+		set directive (string sub -s 2 -- $lines[-1])
+		set -e lines[-1]
+	end
+	if test (math "bitand($directive, 4)") -ne 0
+		__fish_complete_directories $current
+		return
+	end
+	if test (math "bitand($directive, 8)") -ne 0
+		for ext in $lines
+			__fish_complete_suffix .$ext
+		end
+		return
+	end
+	if not set -q lines[1]; and test (math "bitand($directive, 2)") -ne 0
+		__fish_complete_path $current
+		return
+	end
+	printf '%s\n' $lines
 end
 
 # Setup Fish to use the function for completions for '{{.Name}}'
@@ -1,54 +1,55 @@
 package completion
 
 import (
-	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
 
 	"github.com/coder/serpent"
 )
 
 // FileHandler returns a handler that completes file names, using the
-// given filter func, which may be nil.
+// given filter func, which may be nil. The files are listed by the
+// command rather than the shell, so prefer returning a file directive, such
+// as serpent.CompletionFilterFileExt, when one fits.
 func FileHandler(filter func(info os.FileInfo) bool) serpent.CompletionHandlerFunc {
-	return func(inv *serpent.Invocation) []string {
-		var out []string
-		_, word := inv.CurWords()
+	return serpent.FileCompletionHandler(filter)
+}
 
-		dir, _ := filepath.Split(word)
-		if dir == "" {
-			dir = "."
-		}
-		f, err := os.Open(dir)
-		if err != nil {
-			return out
-		}
-		defer f.Close()
-		if dir == "." {
-			dir = ""
-		}
+// YAMLKeyHandler returns a handler that completes the YAML keys of the
+// options of cmd and its parents, as given by Option.YAMLPath. It's meant for
+// "config get/set" style commands. If cmd is nil, the options of the
+// command being completed are used. Hidden options are left out.
+func YAMLKeyHandler(cmd *serpent.Command) serpent.CompletionHandlerFunc {
+	return optionHandler(cmd, serpent.Option.YAMLPath)
+}
 
-		infos, err := f.Readdir(0)
-		if err != nil {
-			return out
-		}
+// EnvHandler returns a handler that completes the environment variable
+// names of the options of cmd and its parents. If cmd is nil, the options
+// of the command being completed are used. Hidden options are left out.
+func EnvHandler(cmd *serpent.Command) serpent.CompletionHandlerFunc {
+	return optionHandler(cmd, func(opt serpent.Option) string {
+		return opt.Env
+	})
+}
 
-		for _, info := range infos {
-			if filter != nil && !filter(info) {
+// optionHandler completes the non-empty keys of the full options of cmd,
+// described by their options' descriptions.
+func optionHandler(cmd *serpent.Command, key func(serpent.Option) string) serpent.CompletionHandlerFunc {
+	return func(inv *serpent.Invocation) []string {
+		c := cmd
+		if c == nil {
+			c = inv.Command
+		}
+		var (
+			out  []string
+			seen = make(map[string]bool)
+		)
+		for _, opt := range c.FullOptions() {
+			k := key(opt)
+			if k == "" || opt.Hidden || seen[k] {
 				continue
 			}
-
-			var cur string
-			if info.IsDir() {
-				cur = fmt.Sprintf("%s%s%c", dir, info.Name(), os.PathSeparator)
-			} else {
-				cur = fmt.Sprintf("%s%s", dir, info.Name())
-			}
-
-			if strings.HasPrefix(cur, word) {
-				out = append(out, cur)
-			}
+			seen[k] = true
+			out = append(out, serpent.DescribeCompletion(k, opt.Description))
 		}
 		return out
 	}
@@ -0,0 +1,78 @@
+package completion
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	home "github.com/mitchellh/go-homedir"
+)
+
+type nushell struct {
+	goos        string
+	programName string
+}
+
+var _ Shell = &nushell{}
+
+func Nushell(goos string, programName string) Shell {
+	return &nushell{goos: goos, programName: programName}
+}
+
+func (n *nushell) Name() string {
+	return "nushell"
+}
+
+func (n *nushell) InstallPath() (string, error) {
+	homeDir, err := home.Dir()
+	if err != nil {
+		return "", err
+	}
+	switch n.goos {
+	case "darwin":
+		return filepath.Join(homeDir, "Library", "Application Support", "nushell", "config.nu"), nil
+	case "windows":
+		if appData := os.Getenv("APPDATA"); appData != "" {
+			return filepath.Join(appData, "nushell", "config.nu"), nil
+		}
+		return filepath.Join(homeDir, "AppData", "Roaming", "nushell", "config.nu"), nil
+	}
+	if xdg := os.Getenv("XDG_CONFIG_HOME"); xdg != "" {
+		return filepath.Join(xdg, "nushell", "config.nu"), nil
+	}
+	return filepath.Join(homeDir, ".config", "nushell", "config.nu"), nil
+}
+
+func (n *nushell) WriteCompletion(w io.Writer) error {
+	return writeConfig(w, nushellCompletionTemplate, n.programName)
+}
+
+func (n *nushell) ProgramName() string {
+	return n.programName
+}
+
+// Nushell only supports a single external completer, so we wrap any
+// existing one and only handle our own program.
+const nushellCompletionTemplate = `
+let _{{.Name}}_previous_completer = $env.config?.completions?.external?.completer?
+let _{{.Name}}_completer = {|spans|
+    with-env { COMPLETION_MODE: 1, COMPLETION_DESCRIPTIONS: 1 } {
+        ^{{.Name}} ...($spans | skip 1)
+    } | lines | each {|line|
+        let parts = ($line | split row "\t")
+        if ($parts | length) > 1 {
+            { value: $parts.0, description: $parts.1 }
+        } else {
+            { value: $parts.0 }
+        }
+    }
+}
+$env.config.completions.external.enable = true
+$env.config.completions.external.completer = {|spans|
+    if ($spans | first) == "{{.Name}}" {
+        do $_{{.Name}}_completer $spans
+    } else if $_{{.Name}}_previous_completer != null {
+        do $_{{.Name}}_previous_completer $spans
+    }
+}
+`
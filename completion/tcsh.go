@@ -0,0 +1,55 @@
+package completion
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	home "github.com/mitchellh/go-homedir"
+)
+
+type tcsh struct {
+	goos        string
+	programName string
+}
+
+var _ Shell = &tcsh{}
+
+func Tcsh(goos string, programName string) Shell {
+	return &tcsh{goos: goos, programName: programName}
+}
+
+func (t *tcsh) Name() string {
+	return "tcsh"
+}
+
+func (t *tcsh) InstallPath() (string, error) {
+	homeDir, err := home.Dir()
+	if err != nil {
+		return "", err
+	}
+	// tcsh only reads ~/.cshrc if there is no ~/.tcshrc.
+	tcshrc := filepath.Join(homeDir, ".tcshrc")
+	if _, err := os.Stat(tcshrc); err != nil {
+		cshrc := filepath.Join(homeDir, ".cshrc")
+		if _, err := os.Stat(cshrc); err == nil {
+			return cshrc, nil
+		}
+	}
+	return tcshrc, nil
+}
+
+func (t *tcsh) WriteCompletion(w io.Writer) error {
+	return writeConfig(w, tcshCompletionTemplate, t.programName)
+}
+
+func (t *tcsh) ProgramName() string {
+	return t.programName
+}
+
+// tcsh can't pass the words being completed as arguments, but it exposes
+// the command line in $COMMAND_LINE, which we forward via COMPLETION_LINE.
+// The :q modifier keeps quotes in the line from breaking the command.
+const tcshCompletionTemplate = `
+complete {{.Name}} 'p,*,` + "`" + `env COMPLETION_MODE=1 COMPLETION_LINE=$COMMAND_LINE:q {{.Name}}` + "`" + `,'
+`
@@ -40,10 +40,47 @@ func (z *zsh) ProgramName() string {
 
 const zshCompletionTemplate = `
 _{{.Name}}_completions() {
-	local -a args completions
+	local -a args lines completions opts
+	local line value directive=0
 	args=("${words[@]:1:$#words}")
-	completions=(${(f)"$(COMPLETION_MODE=1 "{{.Name}}" "${args[@]}")"})
-	compadd -a completions
+	lines=(${(f)"$(COMPLETION_MODE=1 COMPLETION_DESCRIPTIONS=1 COMPLETION_DIRECTIVES=1 COMPLETION_HINTS="${COMPLETION_HINTS:-1}" "{{.Name}}" "${args[@]}")"})
+	# The last line is the directive, a bit set of: 1 no space, 2 file
+	# fallback, 4 directories only, 8 file extension filter.
+	if [[ "${lines[-1]}" == :<-> ]]; then
+		directive=${lines[-1]#:}
+		lines[-1]=()
+	fi
+	for line in ${(M)lines:#:hint:*}; do
+		_message -r "${line#:hint:}"
+	done
+	lines=(${lines:#:hint:*})
+	if (( directive & 4 )); then
+		compset -P '*='
+		_path_files -/
+		return
+	fi
+	if (( directive & 8 )); then
+		compset -P '*='
+		_files -g "*.(${(j:|:)lines})"
+		return
+	fi
+	for line in "${lines[@]}"; do
+		# Each line is "value<TAB>description", and _describe expects
+		# "value:description", so escape any colons in the value.
+		value="${line%%$'\t'*}"
+		value="${value//:/\\:}"
+		if [[ "$line" == *$'\t'* ]]; then
+			completions+=("${value}:${line#*$'\t'}")
+		else
+			completions+=("${value}")
+		fi
+	done
+	if (( directive & 1 )); then
+		opts=(-S '')
+	fi
+	if ! _describe -t values '{{.Name}}' completions "${opts[@]}" && (( directive & 2 )); then
+		_files
+	fi
 }
 compdef _{{.Name}}_completions {{.Name}}
 `
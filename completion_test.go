@@ -4,12 +4,15 @@ import (
 	"fmt"
 	"io"
 	"os"
+	"os/exec"
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	serpent "github.com/coder/serpent"
 	"github.com/coder/serpent/completion"
+	"github.com/coder/serpent/completion/completiontest"
 	"github.com/stretchr/testify/require"
 )
 
@@ -55,7 +58,7 @@ func TestCompletion(t *testing.T) {
 		io := fakeIO(i)
 		err := i.Run()
 		require.NoError(t, err)
-		require.Equal(t, "--req-array\n--req-bool\n--req-enum\n--req-enum-array\n--req-string\n", io.Stdout.String())
+		require.Equal(t, "--req-array\n-a\n--req-bool\n-b\n--req-enum\n--req-enum-array\n--req-string\n-s\n--prefix\n--verbose\n--verbode-old\n", io.Stdout.String())
 	})
 
 	t.Run("ListFlagsAfterArg", func(t *testing.T) {
@@ -65,7 +68,7 @@ func TestCompletion(t *testing.T) {
 		io := fakeIO(i)
 		err := i.Run()
 		require.NoError(t, err)
-		require.Equal(t, "doesntexist.go\n--extra\n", io.Stdout.String())
+		require.Equal(t, "doesntexist.go\n--extra\n--prefix\n--verbose\n--verbode-old\n", io.Stdout.String())
 	})
 
 	t.Run("FlagExhaustive", func(t *testing.T) {
@@ -75,7 +78,7 @@ func TestCompletion(t *testing.T) {
 		io := fakeIO(i)
 		err := i.Run()
 		require.NoError(t, err)
-		require.Equal(t, "--req-array\n--req-enum\n--req-enum-array\n", io.Stdout.String())
+		require.Equal(t, "--req-array\n-a\n--req-enum\n--req-enum-array\n--prefix\n--verbose\n--verbode-old\n", io.Stdout.String())
 	})
 
 	t.Run("FlagShorthand", func(t *testing.T) {
@@ -85,7 +88,7 @@ func TestCompletion(t *testing.T) {
 		io := fakeIO(i)
 		err := i.Run()
 		require.NoError(t, err)
-		require.Equal(t, "--req-array\n--req-enum\n--req-enum-array\n", io.Stdout.String())
+		require.Equal(t, "--req-array\n-a\n--req-enum\n--req-enum-array\n--prefix\n--verbose\n--verbode-old\n", io.Stdout.String())
 	})
 
 	t.Run("NoOptDefValueFlag", func(t *testing.T) {
@@ -148,6 +151,50 @@ func TestCompletion(t *testing.T) {
 		require.Equal(t, "--req-enum-array=foo\n--req-enum-array=bar\n--req-enum-array=qux\n", io.Stdout.String())
 	})
 
+	t.Run("SubcommandDescriptions", func(t *testing.T) {
+		t.Parallel()
+		i := cmd().Invoke("")
+		i.Environ.Set(serpent.CompletionModeEnv, "1")
+		i.Environ.Set(serpent.CompletionDescriptionsEnv, "1")
+		io := fakeIO(i)
+		err := i.Run()
+		require.NoError(t, err)
+		require.Equal(t, "altfile\nfile\nrequired-flag\tExample with required flags\ntoupper\tConverts a word to upper case\n", io.Stdout.String())
+	})
+
+	t.Run("FlagDescriptions", func(t *testing.T) {
+		t.Parallel()
+		i := cmd().Invoke("altfile", "-")
+		i.Environ.Set(serpent.CompletionModeEnv, "1")
+		i.Environ.Set(serpent.CompletionDescriptionsEnv, "1")
+		io := fakeIO(i)
+		err := i.Run()
+		require.NoError(t, err)
+		require.Equal(t, "doesntexist.go\n--extra\tExtra files.\n--prefix\n--verbose\n--verbode-old\n", io.Stdout.String())
+	})
+
+	t.Run("CompletionLine", func(t *testing.T) {
+		t.Parallel()
+		i := cmd().Invoke()
+		i.Environ.Set(serpent.CompletionModeEnv, "1")
+		i.Environ.Set(serpent.CompletionLineEnv, `root required-flag --req-string "foo bar" --req-enum `)
+		io := fakeIO(i)
+		err := i.Run()
+		require.NoError(t, err)
+		require.Equal(t, "foo\nbar\nqux\n", io.Stdout.String())
+	})
+
+	t.Run("CompletionLinePartialQuote", func(t *testing.T) {
+		t.Parallel()
+		i := cmd().Invoke()
+		i.Environ.Set(serpent.CompletionModeEnv, "1")
+		i.Environ.Set(serpent.CompletionLineEnv, `root 'required-flag`)
+		io := fakeIO(i)
+		err := i.Run()
+		require.NoError(t, err)
+		require.Equal(t, "required-flag\n", io.Stdout.String())
+	})
+
 	t.Run("EnumArrayEqualsBeginQuotesOK", func(t *testing.T) {
 		t.Parallel()
 		i := cmd().Invoke("required-flag", "--req-enum-array=\"")
@@ -160,6 +207,661 @@ func TestCompletion(t *testing.T) {
 
 }
 
+func TestCompletionDescriptions(t *testing.T) {
+	t.Parallel()
+
+	cmd := func() *serpent.Command {
+		var format string
+		return &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{
+				{
+					Name:        "format",
+					Flag:        "format",
+					Description: "Output format.",
+					Value: serpent.EnumOfChoices(&format,
+						serpent.EnumChoice{Value: "table", Description: "Render as a table."},
+						serpent.EnumChoice{Value: "json"},
+						serpent.EnumChoice{Value: "yml", Deprecated: true},
+					),
+				},
+			},
+			Handler: func(i *serpent.Invocation) error { return nil },
+		}
+	}
+
+	t.Run("Enabled", func(t *testing.T) {
+		t.Parallel()
+		i := cmd().Invoke("--format", "")
+		i.Environ.Set(serpent.CompletionModeEnv, "1")
+		i.Environ.Set(serpent.CompletionDescriptionsEnv, "1")
+		io := fakeIO(i)
+		require.NoError(t, i.Run())
+		require.Equal(t, "table\tRender as a table.\njson\n", io.Stdout.String())
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+		i := cmd().Invoke("--format=")
+		i.Environ.Set(serpent.CompletionModeEnv, "1")
+		io := fakeIO(i)
+		require.NoError(t, i.Run())
+		require.Equal(t, "--format=table\n--format=json\n", io.Stdout.String())
+	})
+
+	t.Run("Sanitized", func(t *testing.T) {
+		t.Parallel()
+		c := cmd()
+		c.CompletionHandler = func(i *serpent.Invocation) []string {
+			return []string{serpent.DescribeCompletion("value", "First\tline.\nSecond line.")}
+		}
+		i := c.Invoke("")
+		i.Environ.Set(serpent.CompletionModeEnv, "1")
+		i.Environ.Set(serpent.CompletionDescriptionsEnv, "1")
+		io := fakeIO(i)
+		require.NoError(t, i.Run())
+		require.Equal(t, "value\tFirst line.\n", io.Stdout.String())
+	})
+}
+
+func TestCompletionShorthand(t *testing.T) {
+	t.Parallel()
+
+	cmd := func() *serpent.Command {
+		var (
+			all, long bool
+			output    string
+		)
+		return &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{
+				{
+					Name:          "all",
+					Flag:          "all",
+					FlagShorthand: "a",
+					Value:         serpent.BoolOf(&all),
+				},
+				{
+					Name:          "long",
+					Flag:          "long",
+					FlagShorthand: "l",
+					Value:         serpent.BoolOf(&long),
+				},
+				{
+					Name:          "output",
+					Flag:          "output",
+					FlagShorthand: "o",
+					Value:         serpent.EnumOf(&output, "json", "yaml"),
+				},
+			},
+			Handler: func(i *serpent.Invocation) error { return nil },
+		}
+	}
+
+	cases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "SingleDash",
+			args:     []string{"-"},
+			expected: "--all\n-a\n--long\n-l\n--output\n-o\n",
+		},
+		{
+			name:     "DoubleDash",
+			args:     []string{"--"},
+			expected: "--all\n--long\n--output\n",
+		},
+		{
+			name:     "NextWord",
+			args:     []string{"-o", ""},
+			expected: "json\nyaml\n",
+		},
+		{
+			name:     "CombinedNextWord",
+			args:     []string{"-alo", ""},
+			expected: "json\nyaml\n",
+		},
+		{
+			name:     "Equals",
+			args:     []string{"-o="},
+			expected: "-o=json\n-o=yaml\n",
+		},
+		{
+			name:     "Attached",
+			args:     []string{"-oj"},
+			expected: "-ojson\n-oyaml\n",
+		},
+		{
+			name:     "CombinedAttached",
+			args:     []string{"-lo"},
+			expected: "-lo\n",
+		},
+		{
+			name:     "CombinedBooleans",
+			args:     []string{"-al"},
+			expected: "-al\n",
+		},
+		{
+			name:     "Unknown",
+			args:     []string{"-x"},
+			expected: "--all\n--long\n--output\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			i := cmd().Invoke(tc.args...)
+			i.Environ.Set(serpent.CompletionModeEnv, "1")
+			io := fakeIO(i)
+			require.NoError(t, i.Run())
+			require.Equal(t, tc.expected, io.Stdout.String())
+		})
+	}
+}
+
+func TestCompletionInherited(t *testing.T) {
+	t.Parallel()
+
+	cmd := func() *serpent.Command {
+		var (
+			verbose, debug   bool
+			output, format   string
+			childFormat, url string
+		)
+		return &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{
+				{
+					Name:  "verbose",
+					Flag:  "verbose",
+					Value: serpent.BoolOf(&verbose),
+				},
+				{
+					Name:   "debug",
+					Flag:   "debug",
+					Value:  serpent.BoolOf(&debug),
+					Hidden: true,
+				},
+				{
+					Name:          "output",
+					Flag:          "output",
+					FlagShorthand: "o",
+					Value:         serpent.EnumOf(&output, "json", "yaml"),
+				},
+				{
+					Name:  "format",
+					Flag:  "format",
+					Value: serpent.EnumOf(&format, "a", "b"),
+				},
+			},
+			Children: []*serpent.Command{
+				{
+					Use: "sub",
+					Options: serpent.OptionSet{
+						{
+							Name:  "format",
+							Flag:  "format",
+							Value: serpent.EnumOf(&childFormat, "c", "d"),
+						},
+						{
+							Name:  "url",
+							Flag:  "url",
+							Value: serpent.StringOf(&url),
+						},
+					},
+					Handler: func(i *serpent.Invocation) error { return nil },
+				},
+			},
+		}
+	}
+
+	cases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "ListFlags",
+			args:     []string{"sub", "--"},
+			expected: "--format\n--url\n--output\n--verbose\n",
+		},
+		{
+			name:     "Exhausted",
+			args:     []string{"sub", "--verbose", "--url", "x", "--"},
+			expected: "--format\n--output\n",
+		},
+		{
+			name:     "ExhaustedBeforeSubcommand",
+			args:     []string{"--verbose", "sub", "--"},
+			expected: "--format\n--url\n--output\n",
+		},
+		{
+			name:     "InheritedValue",
+			args:     []string{"sub", "--output", ""},
+			expected: "json\nyaml\n",
+		},
+		{
+			name:     "InheritedShorthand",
+			args:     []string{"sub", "-o="},
+			expected: "-o=json\n-o=yaml\n",
+		},
+		{
+			name:     "Shadowed",
+			args:     []string{"sub", "--format", ""},
+			expected: "c\nd\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			i := cmd().Invoke(tc.args...)
+			i.Environ.Set(serpent.CompletionModeEnv, "1")
+			io := fakeIO(i)
+			require.NoError(t, i.Run())
+			require.Equal(t, tc.expected, io.Stdout.String())
+		})
+	}
+}
+
+func TestCompletionHints(t *testing.T) {
+	t.Parallel()
+
+	t.Run("RequiredFlags", func(t *testing.T) {
+		t.Parallel()
+		r := completiontest.Complete(t, sampleCommand(t), "root required-flag ")
+		require.Equal(t, []string{"--req-bool is required", "--req-string is required"}, r.Hints)
+
+		r = completiontest.Complete(t, sampleCommand(t), "root required-flag -b --")
+		require.Equal(t, []string{"--req-string is required"}, r.Hints)
+	})
+
+	cmd := func() *serpent.Command {
+		return &serpent.Command{
+			Use:     "root <workspace>",
+			Handler: func(i *serpent.Invocation) error { return nil },
+			CompletionHandler: func(inv *serpent.Invocation) []string {
+				return []string{serpent.HintCompletion("a workspace name is required\nignored")}
+			},
+		}
+	}
+
+	t.Run("Handler", func(t *testing.T) {
+		t.Parallel()
+		r := completiontest.Complete(t, cmd(), "root ")
+		require.Empty(t, r.Completions)
+		require.Equal(t, []string{"a workspace name is required"}, r.Hints)
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		t.Parallel()
+		for _, v := range []string{"", "0"} {
+			i := cmd().Invoke("")
+			i.Environ.Set(serpent.CompletionModeEnv, "1")
+			if v != "" {
+				i.Environ.Set(serpent.CompletionHintsEnv, v)
+			}
+			io := fakeIO(i)
+			require.NoError(t, i.Run())
+			require.Empty(t, io.Stdout.String())
+		}
+	})
+}
+
+func TestCompletionDirectives(t *testing.T) {
+	t.Parallel()
+
+	cmd := func() *serpent.Command {
+		var name, dir string
+		return &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{
+				{
+					Name:  "name",
+					Flag:  "name",
+					Value: serpent.StringOf(&name),
+					CompletionHandler: func(*serpent.Invocation) []string {
+						return []string{"foo=", serpent.DirectiveCompletion(serpent.CompletionNoSpace)}
+					},
+				},
+				{
+					Name:  "dir",
+					Flag:  "dir",
+					Value: serpent.DirPathOf(&dir),
+				},
+			},
+			Handler: func(i *serpent.Invocation) error { return nil },
+			Children: []*serpent.Command{
+				{Use: "sub"},
+			},
+		}
+	}
+
+	cases := []struct {
+		name       string
+		args       []string
+		directives bool
+		expected   string
+	}{
+		{
+			name:       "NoSpace",
+			args:       []string{"--name", ""},
+			directives: true,
+			expected:   "foo=\n:1\n",
+		},
+		{
+			name:     "NoSpaceUnsupported",
+			args:     []string{"--name", ""},
+			expected: "foo=\n",
+		},
+		{
+			name:       "EqualsFlag",
+			args:       []string{"--name="},
+			directives: true,
+			expected:   "--name=foo=\n:1\n",
+		},
+		{
+			name:       "FilterDirs",
+			args:       []string{"--dir="},
+			directives: true,
+			expected:   ":4\n",
+		},
+		{
+			name:       "Default",
+			args:       []string{""},
+			directives: true,
+			expected:   "sub\n:0\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			i := cmd().Invoke(tc.args...)
+			i.Environ.Set(serpent.CompletionModeEnv, "1")
+			if tc.directives {
+				i.Environ.Set(serpent.CompletionDirectivesEnv, "1")
+			}
+			io := fakeIO(i)
+			require.NoError(t, i.Run())
+			require.Equal(t, tc.expected, io.Stdout.String())
+		})
+	}
+}
+
+func TestCompletionTimeout(t *testing.T) {
+	t.Parallel()
+
+	cmd := func(h serpent.CompletionHandlerFunc) *serpent.Command {
+		var name string
+		return &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{
+				{
+					Name:              "name",
+					Flag:              "name",
+					Value:             serpent.StringOf(&name),
+					CompletionHandler: h,
+				},
+			},
+			Handler:           func(i *serpent.Invocation) error { return nil },
+			CompletionHandler: h,
+			Children: []*serpent.Command{
+				{Use: "sub"},
+			},
+		}
+	}
+	slow := func(inv *serpent.Invocation) []string {
+		<-inv.Context().Done()
+		return []string{"slow"}
+	}
+	panics := func(*serpent.Invocation) []string {
+		panic("boom")
+	}
+
+	cases := []struct {
+		name     string
+		handler  serpent.CompletionHandlerFunc
+		args     []string
+		expected string
+	}{
+		{
+			name:     "Command",
+			handler:  slow,
+			args:     []string{""},
+			expected: "sub\n",
+		},
+		{
+			name:     "Flag",
+			handler:  slow,
+			args:     []string{"--name", ""},
+			expected: "sub\n",
+		},
+		{
+			name:     "Panic",
+			handler:  panics,
+			args:     []string{"--name", ""},
+			expected: "sub\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			i := cmd(tc.handler).Invoke(tc.args...)
+			i.Environ.Set(serpent.CompletionModeEnv, "1")
+			i.Environ.Set(serpent.CompletionTimeoutEnv, "10ms")
+			io := fakeIO(i)
+			require.NoError(t, i.Run())
+			require.Equal(t, tc.expected, io.Stdout.String())
+		})
+	}
+
+	t.Run("Deadline", func(t *testing.T) {
+		t.Parallel()
+		var deadline time.Time
+		i := cmd(func(inv *serpent.Invocation) []string {
+			deadline, _ = inv.Context().Deadline()
+			return nil
+		}).Invoke("")
+		i.Environ.Set(serpent.CompletionModeEnv, "1")
+		_ = fakeIO(i)
+		require.NoError(t, i.Run())
+		require.WithinDuration(t, time.Now().Add(serpent.DefaultCompletionTimeout), deadline, time.Second)
+	})
+}
+
+func TestCompletionCache(t *testing.T) {
+	t.Parallel()
+
+	cmd := func(cache completion.Cache, calls *int) *serpent.Command {
+		return &serpent.Command{
+			Use:     "root",
+			Handler: func(i *serpent.Invocation) error { return nil },
+			CompletionHandler: cache.Handler(func(inv *serpent.Invocation) []string {
+				*calls++
+				return []string{fmt.Sprintf("call%d", *calls)}
+			}),
+		}
+	}
+	complete := func(t *testing.T, cmd *serpent.Command, args ...string) string {
+		t.Helper()
+		i := cmd.Invoke(args...)
+		i.Environ.Set(serpent.CompletionModeEnv, "1")
+		io := fakeIO(i)
+		require.NoError(t, i.Run())
+		return io.Stdout.String()
+	}
+
+	t.Run("Hit", func(t *testing.T) {
+		t.Parallel()
+		var calls int
+		c := cmd(completion.Cache{Dir: t.TempDir(), TTL: time.Hour}, &calls)
+		require.Equal(t, "call1\n", complete(t, c, "a", ""))
+		require.Equal(t, "call1\n", complete(t, c, "a", "x"))
+		require.Equal(t, "call2\n", complete(t, c, "b", ""))
+		require.Equal(t, 2, calls)
+	})
+
+	t.Run("Expired", func(t *testing.T) {
+		t.Parallel()
+		var calls int
+		c := cmd(completion.Cache{Dir: t.TempDir(), TTL: time.Nanosecond}, &calls)
+		require.Equal(t, "call1\n", complete(t, c, ""))
+		require.Equal(t, "call2\n", complete(t, c, ""))
+	})
+}
+
+func TestCompletiontest(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Complete", func(t *testing.T) {
+		t.Parallel()
+		r := completiontest.Complete(t, sampleCommand(t), "root required-flag --req-enum ")
+		require.Equal(t, []string{"foo", "bar", "qux"}, r.Values())
+		require.Zero(t, r.Directive)
+	})
+
+	t.Run("Descriptions", func(t *testing.T) {
+		t.Parallel()
+		r := completiontest.Complete(t, sampleCommand(t), "root to")
+		require.Contains(t, r.Completions, completiontest.Completion{Value: "toupper", Description: "Converts a word to upper case"})
+	})
+
+	t.Run("Cursor", func(t *testing.T) {
+		t.Parallel()
+		line := "root required-flag --req-enum  --req-bool"
+		r := completiontest.CompleteAt(t, sampleCommand(t), line, strings.Index(line, "  ")+1)
+		require.Equal(t, []string{"foo", "bar", "qux"}, r.Values())
+	})
+
+	t.Run("Directive", func(t *testing.T) {
+		t.Parallel()
+		var dir string
+		cmd := &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{
+				{Name: "dir", Flag: "dir", Value: serpent.DirPathOf(&dir)},
+			},
+			Handler: func(i *serpent.Invocation) error { return nil },
+		}
+		r := completiontest.Complete(t, cmd, "root --dir ")
+		require.Empty(t, r.Completions)
+		require.Equal(t, serpent.CompletionFilterDirs, r.Directive)
+	})
+}
+
+func TestCompletiontestShell(t *testing.T) {
+	t.Parallel()
+	if testing.Short() {
+		t.Skip("builds a binary")
+	}
+
+	bin := filepath.Join(t.TempDir(), "completetest")
+	out, err := exec.Command("go", "build", "-o", bin, "./example/completetest").CombinedOutput()
+	require.NoError(t, err, string(out))
+
+	for _, shell := range []string{completion.ShellBash, completiontest.ZshStubbed, completion.ShellFish} {
+		shell := shell
+		t.Run(shell, func(t *testing.T) {
+			t.Parallel()
+
+			got := completiontest.ShellComplete(t, shell, bin, "completetest file --type ")
+			var values []string
+			for _, c := range got {
+				values = append(values, c.Value)
+			}
+			require.ElementsMatch(t, []string{"binary", "text"}, values)
+
+			got = completiontest.ShellComplete(t, shell, bin, "completetest su")
+			require.Len(t, got, 1)
+			require.Equal(t, "sub", got[0].Value)
+		})
+	}
+}
+
+func TestConfigCompletion(t *testing.T) {
+	t.Parallel()
+
+	cmd := func() *serpent.Command {
+		var (
+			address, token, secret string
+			verbose                bool
+		)
+		networking := &serpent.Group{Name: "Networking", YAML: "networking"}
+		http := &serpent.Group{Name: "HTTP", YAML: "http", Parent: networking}
+		root := &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{
+				{
+					Name:        "address",
+					Description: "The address to listen on.",
+					Env:         "ROOT_ADDRESS",
+					YAML:        "address",
+					Group:       http,
+					Value:       serpent.StringOf(&address),
+				},
+				{
+					Name:  "token",
+					Env:   "ROOT_TOKEN",
+					Value: serpent.StringOf(&token),
+				},
+				{
+					Name:   "secret",
+					Env:    "ROOT_SECRET",
+					YAML:   "secret",
+					Hidden: true,
+					Value:  serpent.StringOf(&secret),
+				},
+				{
+					Name:  "verbose",
+					Flag:  "verbose",
+					YAML:  "verbose",
+					Value: serpent.BoolOf(&verbose),
+				},
+			},
+		}
+		root.AddSubcommands(&serpent.Command{
+			Use: "config",
+			Children: []*serpent.Command{
+				{
+					Use:               "get <key>",
+					Handler:           func(i *serpent.Invocation) error { return nil },
+					CompletionHandler: completion.YAMLKeyHandler(root),
+				},
+				{
+					Use:               "env <name>",
+					Handler:           func(i *serpent.Invocation) error { return nil },
+					CompletionHandler: completion.EnvHandler(nil),
+				},
+			},
+		})
+		return root
+	}
+
+	t.Run("YAMLKeys", func(t *testing.T) {
+		t.Parallel()
+		r := completiontest.Complete(t, cmd(), "root config get ")
+		require.Equal(t, []completiontest.Completion{
+			{Value: "networking.http.address", Description: "The address to listen on."},
+			{Value: "verbose"},
+		}, r.Completions)
+	})
+
+	t.Run("EnvNames", func(t *testing.T) {
+		t.Parallel()
+		r := completiontest.Complete(t, cmd(), "root config env ")
+		require.Equal(t, []string{"ROOT_ADDRESS", "ROOT_TOKEN"}, r.Values())
+	})
+}
+
 func TestFileCompletion(t *testing.T) {
 	t.Parallel()
 
@@ -316,6 +1018,200 @@ func TestCompletionInstall(t *testing.T) {
 	}
 }
 
+func TestCompletionUninstall(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		input    []byte
+		expected []byte
+		errMsg   string
+	}{
+		{
+			name:     "NotInstalled",
+			input:    []byte("FAKE_SCRIPT\n"),
+			expected: []byte("FAKE_SCRIPT\n"),
+		},
+		{
+			name:     "Only",
+			input:    []byte("# ============ BEGIN fake COMPLETION ============\nFAKE_COMPLETION\n# ============ END fake COMPLETION ==============\n"),
+			expected: []byte(""),
+		},
+		{
+			name:     "Appended",
+			input:    []byte("FAKE_SCRIPT\n# ============ BEGIN fake COMPLETION ============\nFAKE_COMPLETION\n# ============ END fake COMPLETION ==============\n"),
+			expected: []byte("FAKE_SCRIPT"),
+		},
+		{
+			name:     "Middle",
+			input:    []byte("FAKE_SCRIPT\n# ============ BEGIN fake COMPLETION ============\nOLD_COMPLETION\n# ============ END fake COMPLETION ==============\nFAKE_SCRIPT\n"),
+			expected: []byte("FAKE_SCRIPT\nFAKE_SCRIPT\n"),
+		},
+		{
+			name:   "NoFooter",
+			input:  []byte("FAKE_SCRIPT\n# ============ BEGIN fake COMPLETION ============\nOLD_COMPLETION\n"),
+			errMsg: "missing completion footer",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			path := filepath.Join(dir, "fake.sh")
+			err := os.WriteFile(path, tc.input, 0o644)
+			require.NoError(t, err)
+
+			shell := &fakeShell{baseInstallDir: dir, programName: "fake"}
+			err = completion.UninstallShellCompletion(shell)
+			if tc.errMsg != "" {
+				require.ErrorContains(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			contents, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Equal(t, string(tc.expected), string(contents))
+		})
+	}
+
+	t.Run("RoundTrip", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		path := filepath.Join(dir, "fake.sh")
+		err := os.WriteFile(path, []byte("FAKE_SCRIPT"), 0o644)
+		require.NoError(t, err)
+
+		shell := &fakeShell{baseInstallDir: dir, programName: "fake"}
+		require.NoError(t, completion.InstallShellCompletion(shell))
+		require.NoError(t, completion.UninstallShellCompletion(shell))
+		contents, err := os.ReadFile(path)
+		require.NoError(t, err)
+		require.Equal(t, "FAKE_SCRIPT", string(contents))
+	})
+}
+
+func TestCompletionStatus(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		input    string
+		noFile   bool
+		expected completion.InstallStatus
+	}{
+		{
+			name:     "NoFile",
+			noFile:   true,
+			expected: completion.StatusAbsent,
+		},
+		{
+			name:     "Absent",
+			input:    "FAKE_SCRIPT\n",
+			expected: completion.StatusAbsent,
+		},
+		{
+			name:     "Installed",
+			input:    "FAKE_SCRIPT\n# ============ BEGIN fake COMPLETION ============\nFAKE_COMPLETION\n# ============ END fake COMPLETION ==============\nFAKE_SCRIPT\n",
+			expected: completion.StatusInstalled,
+		},
+		{
+			name:     "Outdated",
+			input:    "# ============ BEGIN fake COMPLETION ============\nOLD_COMPLETION\n# ============ END fake COMPLETION ==============\n",
+			expected: completion.StatusOutdated,
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			dir := t.TempDir()
+			if !tc.noFile {
+				err := os.WriteFile(filepath.Join(dir, "fake.sh"), []byte(tc.input), 0o644)
+				require.NoError(t, err)
+			}
+
+			shell := &fakeShell{baseInstallDir: dir, programName: "fake"}
+			status, err := completion.ShellCompletionStatus(shell)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, status)
+		})
+	}
+}
+
+func TestCompletionCommand(t *testing.T) {
+	t.Parallel()
+
+	root := func() *serpent.Command {
+		return &serpent.Command{
+			Use:      "prog",
+			Children: []*serpent.Command{completion.Command()},
+		}
+	}
+
+	t.Run("Print", func(t *testing.T) {
+		t.Parallel()
+		inv := root().Invoke("completion", "print", "--shell", "bash")
+		io := fakeIO(inv)
+		err := inv.Run()
+		require.NoError(t, err)
+		require.Contains(t, io.Stdout.String(), "complete -F _generate_prog_completions prog")
+	})
+
+	t.Run("IsolatedOptions", func(t *testing.T) {
+		t.Parallel()
+		inv := root().Invoke("completion", "--shell", "fish").WithIsolatedOptions()
+		io := fakeIO(inv)
+		err := inv.Run()
+		require.NoError(t, err)
+		require.Contains(t, io.Stdout.String(), "complete -c prog")
+	})
+
+	t.Run("UnknownShell", func(t *testing.T) {
+		t.Parallel()
+		inv := root().Invoke("completion", "status", "--shell", "csh")
+		_ = fakeIO(inv)
+		err := inv.Run()
+		require.Error(t, err)
+	})
+}
+
+func TestShellByName(t *testing.T) {
+	t.Parallel()
+
+	for _, name := range []string{
+		completion.ShellBash,
+		completion.ShellFish,
+		completion.ShellZsh,
+		completion.ShellPowershell,
+		completion.ShellNushell,
+		completion.ShellElvish,
+		completion.ShellTcsh,
+		"nu",
+	} {
+		name := name
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+			shell, err := completion.ShellByName(name, "fake")
+			require.NoError(t, err)
+
+			var sb strings.Builder
+			require.NoError(t, shell.WriteCompletion(&sb))
+			require.Contains(t, sb.String(), "fake")
+			require.Contains(t, sb.String(), "COMPLETION_MODE")
+			require.Equal(t, "fake", shell.ProgramName())
+
+			var choice string
+			require.NoError(t, completion.ShellOptions(&choice).Set(shell.Name()))
+		})
+	}
+
+	_, err := completion.ShellByName("cmd.exe", "fake")
+	require.Error(t, err)
+}
+
 type fakeShell struct {
 	baseInstallDir string
 	programName    string
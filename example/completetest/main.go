@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"os"
 	"strings"
 
@@ -9,31 +8,6 @@ import (
 	"github.com/coder/serpent/completion"
 )
 
-// installCommand returns a serpent command that helps
-// a user configure their shell to use serpent's completion.
-func installCommand() *serpent.Command {
-	var shell string
-	return &serpent.Command{
-		Use:   "completion [--shell <shell>]",
-		Short: "Generate completion scripts for the given shell.",
-		Handler: func(inv *serpent.Invocation) error {
-			defaultShell, err := completion.DetectUserShell(inv.Command.Parent.Name())
-			if err != nil {
-				return fmt.Errorf("Could not detect user shell, please specify a shell using `--shell`")
-			}
-			return defaultShell.WriteCompletion(inv.Stdout)
-		},
-		Options: serpent.OptionSet{
-			{
-				Flag:          "shell",
-				FlagShorthand: "s",
-				Description:   "The shell to generate a completion script for.",
-				Value:         completion.ShellOptions(&shell),
-			},
-		},
-	}
-}
-
 func main() {
 	var (
 		print    bool
@@ -41,6 +15,8 @@ func main() {
 		fileType string
 		fileArr  []string
 		types    []string
+		config   string
+		dir      string
 	)
 	cmd := serpent.Command{
 		Use:   "completetest <text>",
@@ -113,11 +89,23 @@ func main() {
 						Flag:  "types",
 						Value: serpent.EnumArrayOf(&types, "binary", "text"),
 					},
+					{
+						Name:        "config",
+						Flag:        "config",
+						Description: "A YAML config file.",
+						Value:       &serpent.FilePath{Value: &config, Extensions: []string{"yaml", "yml"}},
+					},
+					{
+						Name:        "dir",
+						Flag:        "dir",
+						Description: "A directory.",
+						Value:       serpent.DirPathOf(&dir),
+					},
 				},
 				CompletionHandler: completion.FileHandler(nil),
 				Middleware:        serpent.RequireNArgs(1),
 			},
-			installCommand(),
+			completion.Command(),
 		},
 	}
 
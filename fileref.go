@@ -0,0 +1,211 @@
+package serpent
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultFileRefMaxSize is the maximum number of bytes a FileRef reads
+// when its MaxSize is unset.
+const DefaultFileRefMaxSize int64 = 1 << 20
+
+var (
+	_ pflag.Value      = (*FileRef[*String])(nil)
+	_ yaml.Unmarshaler = (*FileRef[*String])(nil)
+)
+
+// FileRef wraps a pflag.Value so that large inputs can be loaded from
+// elsewhere. An input of "@path" reads the file at path, and "-" reads the
+// invocation's Stdin. The contents, with a single trailing newline removed,
+// are then passed to the underlying value. A leading "@@" escapes a literal
+// "@", and any other input is passed through unchanged.
+//
+// The same rules apply whether the input comes from a flag, an environment
+// variable or a YAML config.
+type FileRef[T pflag.Value] struct {
+	Value T
+	// MaxSize is the maximum number of bytes read from a file or Stdin.
+	// Inputs over the limit are rejected. If zero, DefaultFileRefMaxSize
+	// is used.
+	MaxSize int64
+
+	stdin *fileRefStdin
+	path  string
+}
+
+func FileRefOf[T pflag.Value](v T) *FileRef[T] {
+	return &FileRef[T]{Value: v}
+}
+
+// Path returns where the current value was read from. It is a file path,
+// "-" for Stdin, or empty if the value was given inline. Combined with the
+// Option's ValueSource, it describes the full provenance of the value.
+func (f *FileRef[T]) Path() string {
+	return f.path
+}
+
+func (f *FileRef[T]) bindInvocation(inv *Invocation) {
+	f.stdin = inv.fileRefStdin
+}
+
+// fileRefStdin reads the Stdin of an invocation for the FileRefs that
+// refer to it. Flags are parsed again at every command depth, so the
+// content is kept for the next parse, which would otherwise find Stdin
+// drained.
+type fileRefStdin struct {
+	r io.Reader
+
+	mu  sync.Mutex
+	byt []byte
+	err error
+	eof bool
+}
+
+// read returns the content of Stdin, reading it up to limit+1 bytes, so
+// that callers can tell whether it's over their limit.
+func (s *fileRefStdin) read(limit int64) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err == nil && !s.eof && int64(len(s.byt)) <= limit {
+		more, err := io.ReadAll(io.LimitReader(s.r, limit+1-int64(len(s.byt))))
+		s.byt = append(s.byt, more...)
+		s.err = err
+		// A short read means Stdin is drained.
+		s.eof = int64(len(s.byt)) <= limit
+	}
+	return s.byt, s.err
+}
+
+func (f *FileRef[T]) maxSize() int64 {
+	if f.MaxSize > 0 {
+		return f.MaxSize
+	}
+	return DefaultFileRefMaxSize
+}
+
+// read resolves input into the contents to set and the path they
+// were read from.
+func (f *FileRef[T]) read(input string) (content string, path string, err error) {
+	var (
+		r     io.Reader
+		limit = f.maxSize()
+		byt   []byte
+	)
+	switch {
+	case strings.HasPrefix(input, "@@"):
+		return input[1:], "", nil
+	case input == "-":
+		if f.stdin == nil {
+			return "", "", xerrors.Errorf("cannot read from stdin: no stdin available")
+		}
+		path = "-"
+		byt, err = f.stdin.read(limit)
+	case strings.HasPrefix(input, "@"):
+		path = input[1:]
+		if path == "" {
+			return "", "", xerrors.Errorf("missing file path after %q", "@")
+		}
+		fi, err := os.Open(path)
+		if err != nil {
+			return "", "", xerrors.Errorf("open file reference: %w", err)
+		}
+		defer fi.Close()
+		r = fi
+	default:
+		return input, "", nil
+	}
+
+	if r != nil {
+		byt, err = io.ReadAll(io.LimitReader(r, limit+1))
+	}
+	if err != nil {
+		return "", "", xerrors.Errorf("read %s: %w", describeFileRefPath(path), err)
+	}
+	if int64(len(byt)) > limit {
+		return "", "", xerrors.Errorf("%s exceeds the maximum size of %d bytes", describeFileRefPath(path), limit)
+	}
+	content = string(byt)
+	content = strings.TrimSuffix(content, "\n")
+	content = strings.TrimSuffix(content, "\r")
+	return content, path, nil
+}
+
+func describeFileRefPath(path string) string {
+	if path == "-" {
+		return "stdin"
+	}
+	return "file " + path
+}
+
+func (f *FileRef[T]) Set(input string) error {
+	content, path, err := f.read(input)
+	if err != nil {
+		return err
+	}
+	err = f.Value.Set(content)
+	if err != nil {
+		if path != "" {
+			return xerrors.Errorf("%s: %w", describeFileRefPath(path), err)
+		}
+		return err
+	}
+	f.path = path
+	return nil
+}
+
+func (f *FileRef[T]) String() string {
+	return f.Value.String()
+}
+
+func (f *FileRef[T]) Type() string {
+	return f.Value.Type()
+}
+
+func (f *FileRef[T]) CloneValue() (pflag.Value, error) {
+	v, err := cloneValueAs(f.Value)
+	if err != nil {
+		return nil, err
+	}
+	c := *f
+	c.Value = v
+	return &c, nil
+}
+
+func (f *FileRef[T]) MarshalYAML() (interface{}, error) {
+	m, ok := any(f.Value).(yaml.Marshaler)
+	if !ok {
+		return f.Value, nil
+	}
+	return m.MarshalYAML()
+}
+
+func (f *FileRef[T]) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode && (n.Value == "-" || strings.HasPrefix(n.Value, "@")) {
+		return f.Set(n.Value)
+	}
+	f.path = ""
+	if um, ok := any(f.Value).(yaml.Unmarshaler); ok {
+		return um.UnmarshalYAML(n)
+	}
+	if n.Kind == yaml.ScalarNode {
+		return f.Value.Set(n.Value)
+	}
+	return n.Decode(f.Value)
+}
+
+func (f *FileRef[T]) MarshalJSON() ([]byte, error) {
+	return json.Marshal(f.Value)
+}
+
+func (f *FileRef[T]) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, f.Value)
+}
+
+func (f *FileRef[T]) Underlying() pflag.Value { return f.Value }
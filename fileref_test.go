@@ -0,0 +1,204 @@
+package serpent_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	serpent "github.com/coder/serpent"
+)
+
+func TestFileRef(t *testing.T) {
+	t.Parallel()
+
+	writeFile := func(t *testing.T, content string) string {
+		t.Helper()
+		path := filepath.Join(t.TempDir(), "input")
+		err := os.WriteFile(path, []byte(content), 0o600)
+		require.NoError(t, err)
+		return path
+	}
+
+	cmd := func(cert *string, ref **serpent.FileRef[*serpent.String], config *serpent.YAMLConfigPath) *serpent.Command {
+		*ref = serpent.FileRefOf(serpent.StringOf(cert))
+		return &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{
+				{
+					Name:  "cert",
+					Flag:  "cert",
+					Env:   "CERT",
+					YAML:  "cert",
+					Value: *ref,
+				},
+				{
+					Name:  "config",
+					Flag:  "config",
+					Value: config,
+				},
+			},
+			Handler: func(i *serpent.Invocation) error {
+				return nil
+			},
+		}
+	}
+
+	t.Run("Inline", func(t *testing.T) {
+		t.Parallel()
+		var (
+			cert   string
+			ref    *serpent.FileRef[*serpent.String]
+			config serpent.YAMLConfigPath
+		)
+		err := cmd(&cert, &ref, &config).Invoke("--cert", "inline").Run()
+		require.NoError(t, err)
+		require.Equal(t, "inline", cert)
+		require.Empty(t, ref.Path())
+	})
+
+	t.Run("Escaped", func(t *testing.T) {
+		t.Parallel()
+		var (
+			cert   string
+			ref    *serpent.FileRef[*serpent.String]
+			config serpent.YAMLConfigPath
+		)
+		err := cmd(&cert, &ref, &config).Invoke("--cert", "@@literal").Run()
+		require.NoError(t, err)
+		require.Equal(t, "@literal", cert)
+		require.Empty(t, ref.Path())
+	})
+
+	t.Run("FlagFile", func(t *testing.T) {
+		t.Parallel()
+		var (
+			cert   string
+			ref    *serpent.FileRef[*serpent.String]
+			config serpent.YAMLConfigPath
+		)
+		path := writeFile(t, "-----BEGIN CERTIFICATE-----\n")
+		c := cmd(&cert, &ref, &config)
+		err := c.Invoke("--cert", "@"+path).Run()
+		require.NoError(t, err)
+		require.Equal(t, "-----BEGIN CERTIFICATE-----", cert)
+		require.Equal(t, path, ref.Path())
+		require.Equal(t, serpent.ValueSourceFlag, c.Options.ByName("cert").ValueSource)
+	})
+
+	t.Run("EnvFile", func(t *testing.T) {
+		t.Parallel()
+		var (
+			cert   string
+			ref    *serpent.FileRef[*serpent.String]
+			config serpent.YAMLConfigPath
+		)
+		path := writeFile(t, "from env")
+		c := cmd(&cert, &ref, &config)
+		inv := c.Invoke()
+		inv.Environ.Set("CERT", "@"+path)
+		err := inv.Run()
+		require.NoError(t, err)
+		require.Equal(t, "from env", cert)
+		require.Equal(t, path, ref.Path())
+		require.Equal(t, serpent.ValueSourceEnv, c.Options.ByName("cert").ValueSource)
+	})
+
+	t.Run("YAMLFile", func(t *testing.T) {
+		t.Parallel()
+		var (
+			cert   string
+			ref    *serpent.FileRef[*serpent.String]
+			config serpent.YAMLConfigPath
+		)
+		path := writeFile(t, "from yaml")
+		configPath := writeFile(t, "cert: \"@"+path+"\"\n")
+		c := cmd(&cert, &ref, &config)
+		err := c.Invoke("--config", configPath).Run()
+		require.NoError(t, err)
+		require.Equal(t, "from yaml", cert)
+		require.Equal(t, path, ref.Path())
+		require.Equal(t, serpent.ValueSourceYAML, c.Options.ByName("cert").ValueSource)
+	})
+
+	t.Run("Stdin", func(t *testing.T) {
+		t.Parallel()
+		var (
+			cert   string
+			ref    *serpent.FileRef[*serpent.String]
+			config serpent.YAMLConfigPath
+		)
+		inv := cmd(&cert, &ref, &config).Invoke("--cert", "-")
+		inv.Stdin = strings.NewReader("from stdin\n")
+		err := inv.Run()
+		require.NoError(t, err)
+		require.Equal(t, "from stdin", cert)
+		require.Equal(t, "-", ref.Path())
+	})
+
+	t.Run("StdinSubcommand", func(t *testing.T) {
+		t.Parallel()
+		var (
+			cert   string
+			ref    *serpent.FileRef[*serpent.String]
+			config serpent.YAMLConfigPath
+		)
+		c := cmd(&cert, &ref, &config)
+		c.Children = []*serpent.Command{{
+			Use: "sub",
+			Handler: func(i *serpent.Invocation) error {
+				return nil
+			},
+		}}
+		// Flags are parsed again for the subcommand, which must not find
+		// Stdin drained.
+		inv := c.Invoke("--cert", "-", "sub")
+		inv.Stdin = strings.NewReader("hello\n")
+		err := inv.Run()
+		require.NoError(t, err)
+		require.Equal(t, "hello", cert)
+		require.Equal(t, "-", ref.Path())
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		t.Parallel()
+		var (
+			cert   string
+			ref    *serpent.FileRef[*serpent.String]
+			config serpent.YAMLConfigPath
+		)
+		path := filepath.Join(t.TempDir(), "missing")
+		err := cmd(&cert, &ref, &config).Invoke("--cert", "@"+path).Run()
+		require.ErrorContains(t, err, "open file reference")
+	})
+
+	t.Run("TooLarge", func(t *testing.T) {
+		t.Parallel()
+		var (
+			cert   string
+			ref    *serpent.FileRef[*serpent.String]
+			config serpent.YAMLConfigPath
+		)
+		path := writeFile(t, strings.Repeat("a", 11))
+		c := cmd(&cert, &ref, &config)
+		ref.MaxSize = 10
+		err := c.Invoke("--cert", "@"+path).Run()
+		require.ErrorContains(t, err, "exceeds the maximum size of 10 bytes")
+	})
+
+	t.Run("TypedValue", func(t *testing.T) {
+		t.Parallel()
+		var n int64
+		ref := serpent.FileRefOf(serpent.Int64Of(&n))
+		require.Equal(t, "int", ref.Type())
+
+		err := ref.Set("@" + writeFile(t, "42\n"))
+		require.NoError(t, err)
+		require.EqualValues(t, 42, n)
+
+		err = ref.Set("@" + writeFile(t, "nope"))
+		require.ErrorContains(t, err, "file ")
+	})
+}
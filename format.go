@@ -0,0 +1,316 @@
+package serpent
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+// The output formats supported by Formatter.
+const (
+	OutputTable    = "table"
+	OutputJSON     = "json"
+	OutputYAML     = "yaml"
+	OutputTemplate = "template"
+)
+
+// Formatter renders the rows a command outputs in the format chosen with
+// the --output, --columns and --template flags of its Options. Rows are
+// structs, and their exported fields are the table columns. A field's
+// column is named by its "table" tag, or its lowercased name, and fields
+// tagged "-" are skipped:
+//
+//	type workspace struct {
+//		Name   string `table:"name" json:"name"`
+//		Status string `table:"status" json:"status"`
+//		ID     string `table:"-" json:"id"`
+//	}
+//
+// JSON and YAML output marshal the rows as they are, ignoring the columns.
+// Templates are executed once per row.
+type Formatter struct {
+	// DefaultFormat is the format used if --output isn't given. If empty,
+	// it's OutputTable.
+	DefaultFormat string
+	// DefaultColumns are the columns shown if --columns isn't given. If
+	// empty, all columns are shown.
+	DefaultColumns []string
+
+	rowType reflect.Type
+	columns []formatColumn
+
+	format   string
+	selected []string
+	template string
+}
+
+type formatColumn struct {
+	name  string
+	index []int
+}
+
+// NewFormatter returns a Formatter for rows of the same type as row, which
+// must be a struct or a pointer to one.
+func NewFormatter(row any) *Formatter {
+	typ := reflect.TypeOf(row)
+	for typ != nil && typ.Kind() == reflect.Pointer {
+		typ = typ.Elem()
+	}
+	if typ == nil || typ.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("formatter rows must be structs, got %T", row))
+	}
+
+	f := &Formatter{rowType: typ}
+	for _, field := range reflect.VisibleFields(typ) {
+		if field.Anonymous || !field.IsExported() {
+			continue
+		}
+		name, _, _ := strings.Cut(field.Tag.Get("table"), ",")
+		if name == "-" {
+			continue
+		}
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+		f.columns = append(f.columns, formatColumn{name: name, index: field.Index})
+	}
+	return f
+}
+
+// Columns returns the names of the table columns, in field order.
+func (f *Formatter) Columns() []string {
+	names := make([]string, 0, len(f.columns))
+	for _, c := range f.columns {
+		names = append(names, c.name)
+	}
+	return names
+}
+
+func (f *Formatter) defaultFormat() string {
+	if f.DefaultFormat == "" {
+		return OutputTable
+	}
+	return f.DefaultFormat
+}
+
+// Options returns the --output, --columns and --template options, to be
+// added to the options of the commands that call Format. Only --output has
+// a shorthand, -o, so that -c and -t stay free for the commands.
+func (f *Formatter) Options() OptionSet {
+	defaultColumns := f.DefaultColumns
+	if len(defaultColumns) == 0 {
+		defaultColumns = f.Columns()
+	}
+	return OptionSet{
+		{
+			Name:          "output",
+			Flag:          "output",
+			FlagShorthand: "o",
+			Description:   "Output format.",
+			Default:       f.defaultFormat(),
+			Value:         EnumOf(&f.format, OutputTable, OutputJSON, OutputYAML, OutputTemplate),
+		},
+		{
+			Name:              "columns",
+			Flag:              "columns",
+			Description:       "Columns to show in the table output, separated by commas.",
+			Default:           strings.Join(defaultColumns, ","),
+			Value:             EnumArrayOf(&f.selected, f.Columns()...),
+			CompletionHandler: f.completeColumns,
+		},
+		{
+			Name:        "template",
+			Flag:        "template",
+			Description: "Go template to render every row with, if the output format is template.",
+			Value:       StringOf(&f.template),
+		},
+	}
+}
+
+// completeColumns completes the last of a comma-separated list of columns,
+// leaving out the columns already in the list.
+func (f *Formatter) completeColumns(inv *Invocation) []string {
+	_, cur := inv.CurWords()
+	if strings.HasPrefix(cur, "-") {
+		// The value is in the flag's word, e.g. "--columns=name,".
+		_, cur, _ = strings.Cut(cur, "=")
+	}
+	var listed []string
+	if i := strings.LastIndex(cur, ","); i >= 0 {
+		listed = strings.Split(cur[:i], ",")
+		cur = cur[:i+1]
+	} else {
+		cur = ""
+	}
+
+	var out []string
+	for _, name := range f.Columns() {
+		if !slices.Contains(listed, name) {
+			out = append(out, cur+name)
+		}
+	}
+	if len(out) > 1 {
+		// More columns may follow.
+		out = append(out, DirectiveCompletion(CompletionNoSpace))
+	}
+	return out
+}
+
+// Format writes rows to the invocation's Stdout in the format the user
+// chose. rows is a slice of structs or pointers to structs of the
+// formatter's row type, or a single one. Nil rows are only written by the
+// json and yaml formats, as null.
+func (f *Formatter) Format(inv *Invocation, rows any) error {
+	format, columns, tpl := f.parsed(inv)
+	switch format {
+	case OutputJSON:
+		byt, err := json.MarshalIndent(rows, "", "  ")
+		if err != nil {
+			return xerrors.Errorf("marshal json: %w", err)
+		}
+		_, err = fmt.Fprintf(inv.Stdout, "%s\n", byt)
+		return err
+	case OutputYAML:
+		byt, err := yaml.Marshal(rows)
+		if err != nil {
+			return xerrors.Errorf("marshal yaml: %w", err)
+		}
+		_, err = inv.Stdout.Write(byt)
+		return err
+	case OutputTemplate:
+		return f.formatTemplate(inv, rows, tpl)
+	case OutputTable:
+		return f.formatTable(inv, rows, columns)
+	default:
+		return xerrors.Errorf("unknown output format %q", format)
+	}
+}
+
+// parsed returns the format, columns and template the invocation parsed.
+// They are looked up in the invocation's options, so that invocations with
+// isolated options are formatted as they asked.
+func (f *Formatter) parsed(inv *Invocation) (format string, columns []string, tpl string) {
+	format, columns = f.defaultFormat(), f.DefaultColumns
+	if len(columns) == 0 {
+		columns = f.Columns()
+	}
+	opts := inv.Options()
+	if opt := opts.ByName("output"); opt != nil && opt.Value.String() != "" {
+		format = opt.Value.String()
+	}
+	if opt := opts.ByName("columns"); opt != nil {
+		if sv, ok := opt.Value.(interface{ GetSlice() []string }); ok && len(sv.GetSlice()) > 0 {
+			columns = sv.GetSlice()
+		}
+	}
+	if opt := opts.ByName("template"); opt != nil {
+		tpl = opt.Value.String()
+	}
+	return format, columns, tpl
+}
+
+// formatRows returns the rows of a slice, or rows itself as a single row.
+// Nil rows are left out, so that nil is no rows at all.
+func formatRows(rows any) []reflect.Value {
+	var out []reflect.Value
+	add := func(row reflect.Value) {
+		if !row.IsValid() {
+			return
+		}
+		if (row.Kind() == reflect.Pointer || row.Kind() == reflect.Interface) && row.IsNil() {
+			return
+		}
+		out = append(out, row)
+	}
+	v := reflect.ValueOf(rows)
+	if v.Kind() != reflect.Slice && v.Kind() != reflect.Array {
+		add(v)
+		return out
+	}
+	for i := 0; i < v.Len(); i++ {
+		add(v.Index(i))
+	}
+	return out
+}
+
+func (f *Formatter) formatTemplate(inv *Invocation, rows any, tpl string) error {
+	if tpl == "" {
+		return xerrors.Errorf("the %s output format requires --template", OutputTemplate)
+	}
+	t, err := template.New("output").Parse(tpl)
+	if err != nil {
+		return xerrors.Errorf("parse template: %w", err)
+	}
+	for _, row := range formatRows(rows) {
+		err = t.Execute(inv.Stdout, row.Interface())
+		if err != nil {
+			return xerrors.Errorf("execute template: %w", err)
+		}
+		_, err = fmt.Fprintln(inv.Stdout)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (f *Formatter) formatTable(inv *Invocation, rows any, names []string) error {
+	columns := make([]formatColumn, 0, len(names))
+	for _, name := range names {
+		i := slices.IndexFunc(f.columns, func(c formatColumn) bool { return c.name == name })
+		if i < 0 {
+			return xerrors.Errorf("unknown column %q, should be one of %v", name, f.Columns())
+		}
+		columns = append(columns, f.columns[i])
+	}
+
+	// The table is aligned like the help output.
+	tw := tabwriter.NewWriter(inv.Stdout, 0, 0, 2, ' ', 0)
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = strings.ToUpper(c.name)
+	}
+	_, _ = fmt.Fprintln(tw, strings.Join(headers, "\t"))
+
+	for _, row := range formatRows(rows) {
+		for row.Kind() == reflect.Pointer || row.Kind() == reflect.Interface {
+			row = row.Elem()
+		}
+		if !row.IsValid() {
+			continue
+		}
+		if row.Type() != f.rowType {
+			return xerrors.Errorf("row of type %s, want %s", row.Type(), f.rowType)
+		}
+		cells := make([]string, len(columns))
+		for i, c := range columns {
+			cells[i] = formatCell(row, c.index)
+		}
+		_, _ = fmt.Fprintln(tw, strings.Join(cells, "\t"))
+	}
+	return tw.Flush()
+}
+
+// formatCell renders the field at index of row for a table cell.
+func formatCell(row reflect.Value, index []int) string {
+	v, err := row.FieldByIndexErr(index)
+	if err != nil {
+		// The field is in a nil embedded struct.
+		return ""
+	}
+	for v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return ""
+		}
+		v = v.Elem()
+	}
+	s := fmt.Sprint(v.Interface())
+	return strings.NewReplacer("\t", " ", "\n", " ").Replace(s)
+}
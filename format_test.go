@@ -0,0 +1,273 @@
+package serpent_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	serpent "github.com/coder/serpent"
+	"github.com/coder/serpent/serpenttest"
+)
+
+type formatRow struct {
+	Name   string  `table:"name" json:"name" yaml:"name"`
+	Status string  `table:"status" json:"status" yaml:"status"`
+	Owner  *string `json:"owner,omitempty" yaml:"owner,omitempty"`
+	ID     int     `table:"-" json:"id" yaml:"id"`
+}
+
+func formatCommand() *serpent.Command {
+	owner := "alice"
+	rows := []formatRow{
+		{Name: "dev", Status: "running", Owner: &owner, ID: 1},
+		{Name: "staging-two", Status: "stopped", ID: 2},
+	}
+	f := serpent.NewFormatter(formatRow{})
+	f.DefaultColumns = []string{"name", "status"}
+	return &serpent.Command{
+		Use:     "list",
+		Options: f.Options(),
+		Handler: func(inv *serpent.Invocation) error {
+			return f.Format(inv, rows)
+		},
+	}
+}
+
+func TestFormatter(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		args     []string
+		expected string
+		err      string
+	}{
+		{
+			name: "Table",
+			expected: "NAME         STATUS\n" +
+				"dev          running\n" +
+				"staging-two  stopped\n",
+		},
+		{
+			name: "Columns",
+			args: []string{"--columns", "owner,name"},
+			expected: "OWNER  NAME\n" +
+				"alice  dev\n" +
+				"       staging-two\n",
+		},
+		{
+			name: "UnknownColumn",
+			args: []string{"--columns", "id"},
+			err:  "invalid choice: id",
+		},
+		{
+			name: "JSON",
+			args: []string{"-o", "json"},
+			expected: `[
+  {
+    "name": "dev",
+    "status": "running",
+    "owner": "alice",
+    "id": 1
+  },
+  {
+    "name": "staging-two",
+    "status": "stopped",
+    "id": 2
+  }
+]
+`,
+		},
+		{
+			name: "YAML",
+			args: []string{"--output=yaml"},
+			expected: "- name: dev\n" +
+				"  status: running\n" +
+				"  owner: alice\n" +
+				"  id: 1\n" +
+				"- name: staging-two\n" +
+				"  status: stopped\n" +
+				"  id: 2\n",
+		},
+		{
+			name:     "Template",
+			args:     []string{"-o", "template", "--template", "{{.ID}}={{.Name}}"},
+			expected: "1=dev\n2=staging-two\n",
+		},
+		{
+			name: "TemplateMissing",
+			args: []string{"-o", "template"},
+			err:  "requires --template",
+		},
+		{
+			name: "UnknownFormat",
+			args: []string{"-o", "xml"},
+			err:  "invalid choice: xml",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := serpenttest.Run(t, formatCommand(), serpenttest.RunOptions{Args: tc.args})
+			if tc.err != "" {
+				require.ErrorContains(t, res.Err, tc.err)
+				return
+			}
+			require.NoError(t, res.Err)
+			require.Equal(t, tc.expected, res.Stdout)
+		})
+	}
+
+	t.Run("Isolated", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := formatCommand()
+		for _, args := range [][]string{{"--columns", "status"}, {"--columns", "name"}} {
+			inv := cmd.Invoke(args...).WithIsolatedOptions()
+			io := fakeIO(inv)
+			require.NoError(t, inv.Run())
+			require.Contains(t, io.Stdout.String(), strings.ToUpper(args[1])+"\n")
+		}
+	})
+
+	t.Run("Shorthand", func(t *testing.T) {
+		t.Parallel()
+
+		// Only --output has a shorthand, callers can add their own.
+		res := serpenttest.Run(t, formatCommand(), serpenttest.RunOptions{Args: []string{"-c", "name"}})
+		require.ErrorContains(t, res.Err, "unknown shorthand flag: 'c'")
+
+		cmd := formatCommand()
+		cmd.Options.ByName("columns").FlagShorthand = "c"
+		res = serpenttest.Run(t, cmd, serpenttest.RunOptions{Args: []string{"-c", "name"}})
+		require.NoError(t, res.Err)
+		require.Equal(t, "NAME\ndev\nstaging-two\n", res.Stdout)
+	})
+
+	t.Run("SingleRow", func(t *testing.T) {
+		t.Parallel()
+
+		f := serpent.NewFormatter(&formatRow{})
+		require.Equal(t, []string{"name", "status", "owner"}, f.Columns())
+		inv := (&serpent.Command{Use: "get", Options: f.Options()}).Invoke()
+		inv.Command.Handler = func(inv *serpent.Invocation) error {
+			return f.Format(inv, &formatRow{Name: "dev"})
+		}
+		io := fakeIO(inv)
+		require.NoError(t, inv.Run())
+		require.Equal(t, "NAME  STATUS  OWNER\ndev           \n", io.Stdout.String())
+	})
+}
+
+func TestFormatterNoRows(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name string
+		rows any
+		// expected is the output in the table, json, yaml and template
+		// formats.
+		expected [4]string
+	}{
+		{
+			name:     "Nil",
+			rows:     nil,
+			expected: [4]string{"NAME  STATUS\n", "null\n", "null\n", ""},
+		},
+		{
+			name:     "NilRow",
+			rows:     (*formatRow)(nil),
+			expected: [4]string{"NAME  STATUS\n", "null\n", "null\n", ""},
+		},
+		{
+			name:     "NilSlice",
+			rows:     []formatRow(nil),
+			expected: [4]string{"NAME  STATUS\n", "null\n", "[]\n", ""},
+		},
+		{
+			name:     "Empty",
+			rows:     []formatRow{},
+			expected: [4]string{"NAME  STATUS\n", "[]\n", "[]\n", ""},
+		},
+		{
+			name:     "NilElements",
+			rows:     []*formatRow{nil, {Name: "dev"}},
+			expected: [4]string{"NAME  STATUS\ndev   \n", "[\n  null,\n  {\n    \"name\": \"dev\",\n    \"status\": \"\",\n    \"id\": 0\n  }\n]\n", "- null\n- name: dev\n  status: \"\"\n  id: 0\n", "dev\n"},
+		},
+	}
+
+	formats := []string{"table", "json", "yaml", "template"}
+	for _, tc := range cases {
+		tc := tc
+		for i, format := range formats {
+			i, format := i, format
+			t.Run(tc.name+"/"+format, func(t *testing.T) {
+				t.Parallel()
+
+				f := serpent.NewFormatter(formatRow{})
+				f.DefaultColumns = []string{"name", "status"}
+				cmd := &serpent.Command{
+					Use:     "list",
+					Options: f.Options(),
+					Handler: func(inv *serpent.Invocation) error {
+						return f.Format(inv, tc.rows)
+					},
+				}
+				res := serpenttest.Run(t, cmd, serpenttest.RunOptions{
+					Args: []string{"-o", format, "--template", "{{.Name}}"},
+				})
+				require.NoError(t, res.Err)
+				require.Equal(t, tc.expected[i], res.Stdout)
+			})
+		}
+	}
+}
+
+func TestFormatterCompletion(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name     string
+		args     []string
+		expected string
+	}{
+		{
+			name:     "Empty",
+			args:     []string{"--columns", ""},
+			expected: "name\nstatus\nowner\n:1\n",
+		},
+		{
+			name:     "List",
+			args:     []string{"--columns", "status,"},
+			expected: "status,name\nstatus,owner\n:1\n",
+		},
+		{
+			name:     "Last",
+			args:     []string{"--columns=status,owner,"},
+			expected: "--columns=status,owner,name\n:0\n",
+		},
+		{
+			name:     "Output",
+			args:     []string{"--output="},
+			expected: "--output=table\n--output=json\n--output=yaml\n--output=template\n:0\n",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			i := formatCommand().Invoke(tc.args...)
+			i.Environ.Set(serpent.CompletionModeEnv, "1")
+			i.Environ.Set(serpent.CompletionDirectivesEnv, "1")
+			io := fakeIO(i)
+			require.NoError(t, i.Run())
+			require.Equal(t, tc.expected, io.Stdout.String())
+		})
+	}
+}
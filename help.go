@@ -3,19 +3,16 @@ package serpent
 import (
 	"bufio"
 	_ "embed"
-	"flag"
 	"fmt"
-	"os"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
-	"sync"
 	"text/tabwriter"
 	"text/template"
 
 	"github.com/mitchellh/go-wordwrap"
 	"github.com/muesli/termenv"
-	"golang.org/x/crypto/ssh/terminal"
 	"golang.org/x/xerrors"
 
 	"github.com/coder/pretty"
@@ -30,59 +27,37 @@ type optionGroup struct {
 	Options     OptionSet
 }
 
-func ttyWidth() int {
-	width, _, err := terminal.GetSize(0)
-	if err != nil {
-		return 80
-	}
-	return width
-}
-
-// wrapTTY wraps a string to the width of the terminal, or 80 no terminal
-// is detected.
-func wrapTTY(s string) string {
-	return wordwrap.WrapString(s, uint(ttyWidth()))
-}
-
-var (
-	helpColorProfile termenv.Profile
-	helpColorOnce    sync.Once
-)
-
-// Color returns a color for the given string.
-func helpColor(s string) termenv.Color {
-	helpColorOnce.Do(func() {
-		helpColorProfile = termenv.NewOutput(os.Stdout).ColorProfile()
-		if flag.Lookup("test.v") != nil {
-			// Use a consistent colorless profile in tests so that results
-			// are deterministic.
-			helpColorProfile = termenv.Ascii
-		}
-	})
-	return helpColorProfile.Color(s)
+// wrapWidth wraps a string to the given width.
+func wrapWidth(s string, width int) string {
+	return wordwrap.WrapString(s, uint(width))
 }
 
 // prettyHeader formats a header string with consistent styling.
 // It uppercases the text, adds a colon, and applies the header color.
-func prettyHeader(s string) string {
-	headerFg := pretty.FgColor(helpColor("#337CA0"))
+func prettyHeader(profile termenv.Profile, s string) string {
+	headerFg := pretty.FgColor(profile.Color("#337CA0"))
 	s = strings.ToUpper(s)
 	txt := pretty.String(s, ":")
 	headerFg.Format(txt)
 	return txt.String()
 }
 
-var defaultHelpTemplate = func() *template.Template {
+// helpTemplate returns the help template rendered for term. The template
+// is built per invocation so that concurrent invocations can render to
+// terminals of different widths and color support.
+func helpTemplate(term Terminal) *template.Template {
 	var (
+		twidth   = term.Width()
+		profile  = term.ColorProfile()
 		optionFg = pretty.FgColor(
-			helpColor("#04A777"),
+			profile.Color("#04A777"),
 		)
 	)
 	return template.Must(
 		template.New("usage").Funcs(
 			template.FuncMap{
 				"wrapTTY": func(s string) string {
-					return wrapTTY(s)
+					return wrapWidth(s, twidth)
 				},
 				"trimNewline": func(s string) string {
 					return strings.TrimSuffix(s, "\n")
@@ -92,23 +67,24 @@ var defaultHelpTemplate = func() *template.Template {
 					optionFg.Format(txt)
 					return txt.String()
 				},
-				"prettyHeader": prettyHeader,
+				"prettyHeader": func(s string) string {
+					return prettyHeader(profile, s)
+				},
 				"typeHelper": func(opt *Option) string {
 					switch v := opt.Value.(type) {
 					case *Enum:
-						return strings.Join(v.Choices, "|")
+						return strings.Join(enumChoiceValues(visibleEnumChoices(v.Choices, v.Details)), "|")
 					case *EnumArray:
-						return fmt.Sprintf("[%s]", strings.Join(v.Choices, "|"))
+						return fmt.Sprintf("[%s]", strings.Join(enumChoiceValues(visibleEnumChoices(v.Choices, v.Details)), "|"))
 					default:
 						return v.Type()
 					}
 				},
+				"enumChoiceHelp": enumChoiceHelp,
 				"joinStrings": func(s []string) string {
 					return strings.Join(s, ", ")
 				},
 				"indent": func(body string, spaces int) string {
-					twidth := ttyWidth()
-
 					spacing := strings.Repeat(" ", spaces)
 
 					wrapLim := twidth - len(spacing)
@@ -151,8 +127,6 @@ var defaultHelpTemplate = func() *template.Template {
 					// next line.
 					descStart := sb.Len()
 
-					twidth := ttyWidth()
-
 					for i, line := range strings.Split(
 						wordwrap.WrapString(cmd.Short, uint(twidth-descStart)), "\n",
 					) {
@@ -206,7 +180,7 @@ var defaultHelpTemplate = func() *template.Template {
 				"formatGroupDescription": func(s string) string {
 					s = strings.ReplaceAll(s, "\n", "")
 					s = s + "\n"
-					s = wrapTTY(s)
+					s = wrapWidth(s, twidth)
 					return s
 				},
 				"visibleChildren": func(cmd *Command) []*Command {
@@ -221,13 +195,15 @@ var defaultHelpTemplate = func() *template.Template {
 						Description: "",
 					}}
 
-					// Sort options lexicographically.
-					sort.Slice(cmd.Options, func(i, j int) bool {
-						return cmd.Options[i].Name < cmd.Options[j].Name
+					// Sort options lexicographically. The command's options are
+					// copied, as they may be shared with other invocations.
+					opts := slices.Clone(cmd.Options)
+					sort.Slice(opts, func(i, j int) bool {
+						return opts[i].Name < opts[j].Name
 					})
 
 				optionLoop:
-					for _, opt := range cmd.Options {
+					for _, opt := range opts {
 						if opt.Hidden {
 							continue
 						}
@@ -266,7 +242,51 @@ var defaultHelpTemplate = func() *template.Template {
 			},
 		).Parse(helpTemplateRaw),
 	)
-}()
+}
+
+// enumChoiceHelp lists the choices of an enum option alongside their
+// descriptions. It returns an empty string if no choice has anything to
+// say beyond its name.
+func enumChoiceHelp(opt Option) string {
+	var choices []EnumChoice
+	switch v := opt.Value.(type) {
+	case *Enum:
+		choices = v.ChoiceDetails()
+	case *EnumArray:
+		choices = v.ChoiceDetails()
+	default:
+		return ""
+	}
+
+	var (
+		detailed bool
+		width    int
+	)
+	for _, c := range choices {
+		if c.Description != "" || len(c.Aliases) > 0 || c.Deprecated {
+			detailed = true
+		}
+		if len(c.Value) > width {
+			width = len(c.Value)
+		}
+	}
+	if !detailed {
+		return ""
+	}
+
+	var sb strings.Builder
+	for _, c := range choices {
+		desc := c.Description
+		if len(c.Aliases) > 0 {
+			desc = strings.TrimSpace(fmt.Sprintf("%s (aliases: %s)", desc, strings.Join(c.Aliases, ", ")))
+		}
+		if c.Deprecated {
+			desc = strings.TrimSpace("DEPRECATED. " + desc)
+		}
+		_, _ = fmt.Fprintf(&sb, "%s%s  %s\n", c.Value, strings.Repeat(" ", width-len(c.Value)), desc)
+	}
+	return sb.String()
+}
 
 func filterSlice[T any](s []T, f func(T) bool) []T {
 	var r []T
@@ -344,7 +364,7 @@ func DefaultHelpFn() HandlerFunc {
 		outBuf := bufio.NewWriter(inv.Stdout)
 		out := newlineLimiter{w: outBuf, limit: 2}
 		tabwriter := tabwriter.NewWriter(&out, 0, 0, 2, ' ', 0)
-		err := defaultHelpTemplate.Execute(tabwriter, inv.Command)
+		err := helpTemplate(inv.Terminal()).Execute(tabwriter, inv.Command)
 		if err != nil {
 			return xerrors.Errorf("execute template: %w", err)
 		}
@@ -49,6 +49,7 @@
             {{- $desc := $option.Description }}
 {{ indent $desc 10 }}
 {{- if isDeprecated $option }}{{ indent (printf "DEPRECATED: Use %s instead." (useInstead $option)) 10 }}{{ end }}
+{{- with enumChoiceHelp $option }}{{ indent . 12 }}{{ end }}
         {{- end -}}
     {{- end }}
 {{- end }}
@@ -20,10 +20,14 @@ const (
 	ValueSourceEnv     ValueSource = "env"
 	ValueSourceYAML    ValueSource = "yaml"
 	ValueSourceDefault ValueSource = "default"
+	// ValueSourcePrompt is the source of values the user entered when
+	// prompted for a missing required option.
+	ValueSourcePrompt ValueSource = "prompt"
 )
 
 var valueSourcePriority = []ValueSource{
 	ValueSourceFlag,
+	ValueSourcePrompt,
 	ValueSourceEnv,
 	ValueSourceYAML,
 	ValueSourceDefault,
@@ -207,6 +211,32 @@ func (optSet *OptionSet) Add(opts ...Option) {
 	*optSet = append(*optSet, opts...)
 }
 
+// clone returns a copy of the option set whose values are backed by new
+// storage. Options that share a value share its clone. It fails if a value
+// doesn't implement ValueCloner.
+func (optSet OptionSet) clone() (OptionSet, error) {
+	var (
+		cpy    = make(OptionSet, len(optSet))
+		clones = make(map[pflag.Value]pflag.Value)
+	)
+	for i, opt := range optSet {
+		if opt.Value != nil {
+			v, ok := clones[opt.Value]
+			if !ok {
+				var err error
+				v, err = cloneValue(opt.Value)
+				if err != nil {
+					return nil, xerrors.Errorf("option %q: %w", opt.Name, err)
+				}
+				clones[opt.Value] = v
+			}
+			opt.Value = v
+		}
+		cpy[i] = opt
+	}
+	return cpy, nil
+}
+
 // Filter will only return options that match the given filter. (return true)
 func (optSet OptionSet) Filter(filter func(opt Option) bool) OptionSet {
 	cpy := make(OptionSet, 0)
@@ -424,6 +454,16 @@ func (optSet *OptionSet) SetDefaults() error {
 		}
 	}
 
+	// Now that every source is settled, let values that care know where
+	// they came from.
+	for _, opt := range *optSet {
+		walkValue(opt.Value, func(v pflag.Value) {
+			if r, ok := v.(valueSourceRecorder); ok {
+				r.recordValueSource(opt.ValueSource)
+			}
+		})
+	}
+
 	return merr.ErrorOrNil()
 }
 
@@ -438,6 +478,45 @@ func (optSet OptionSet) ByName(name string) *Option {
 	return nil
 }
 
+func (optSet OptionSet) ByShorthand(shorthand string) *Option {
+	if shorthand == "" {
+		return nil
+	}
+	for i := range optSet {
+		opt := &optSet[i]
+		if opt.FlagShorthand == shorthand {
+			return opt
+		}
+	}
+	return nil
+}
+
+// parseShorthands parses word as one or more combined shorthand flags, such
+// as "-abc", "-ovalue" or "-o=value". All but the last flag must be
+// booleans. If a flag takes a value, it is returned along with the index in
+// word where the value starts; "=" is skipped. ok is false if word isn't
+// made of known shorthands.
+func (optSet OptionSet) parseShorthands(word string) (opt *Option, valueStart int, ok bool) {
+	if len(word) < 2 || word[0] != '-' || word[1] == '-' {
+		return nil, 0, false
+	}
+	for i, r := range word[1:] {
+		opt := optSet.ByShorthand(string(r))
+		if opt == nil {
+			return nil, 0, false
+		}
+		if no, ok := opt.Value.(NoOptDefValuer); ok && no.NoOptDefValue() != "" {
+			continue
+		}
+		valueStart = i + 1 + len(string(r))
+		if strings.HasPrefix(word[valueStart:], "=") {
+			valueStart++
+		}
+		return opt, valueStart, true
+	}
+	return nil, 0, true
+}
+
 func (optSet OptionSet) ByFlag(flag string) *Option {
 	if flag == "" {
 		return nil
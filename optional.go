@@ -0,0 +1,168 @@
+package serpent
+
+import (
+	"encoding/json"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	_ pflag.Value      = (*Optional[*Int64])(nil)
+	_ yaml.Marshaler   = (*Optional[*Int64])(nil)
+	_ yaml.Unmarshaler = (*Optional[*Int64])(nil)
+)
+
+// valueSourceRecorder is implemented by values that want to know which
+// source they were ultimately set from.
+type valueSourceRecorder interface {
+	recordValueSource(src ValueSource)
+}
+
+// Optional wraps a pflag.Value to distinguish a value that was never set
+// from one that was explicitly set to its zero value, such as "0", "false"
+// or "".
+//
+// Defaults count as being set, since they are applied through Set. Use
+// Source to tell a default apart from user input. The source is recorded
+// when the option set's defaults are applied, which happens at the end of
+// every invocation's option parsing.
+//
+// Unset values marshal as null in JSON and YAML, and null unmarshals to
+// an unset value.
+type Optional[T pflag.Value] struct {
+	Value T
+
+	set    bool
+	source ValueSource
+}
+
+func OptionalOf[T pflag.Value](v T) *Optional[T] {
+	return &Optional[T]{Value: v}
+}
+
+// Get returns the underlying value and whether it was set.
+func (o *Optional[T]) Get() (T, bool) {
+	return o.Value, o.set
+}
+
+// IsSet returns true if the value was set by any source, including a
+// default.
+func (o *Optional[T]) IsSet() bool {
+	return o.set
+}
+
+// Source returns where the value came from, or ValueSourceNone if it has
+// not been set.
+func (o *Optional[T]) Source() ValueSource {
+	if !o.set {
+		return ValueSourceNone
+	}
+	return o.source
+}
+
+func (o *Optional[T]) recordValueSource(src ValueSource) {
+	o.source = src
+}
+
+// Unset resets the value to the unset state. The underlying value is left
+// untouched.
+func (o *Optional[T]) Unset() {
+	o.set = false
+	o.source = ValueSourceNone
+}
+
+func (o *Optional[T]) Set(s string) error {
+	err := o.Value.Set(s)
+	if err != nil {
+		return err
+	}
+	o.set = true
+	return nil
+}
+
+func (o *Optional[T]) String() string {
+	if !o.set {
+		return ""
+	}
+	return o.Value.String()
+}
+
+func (o *Optional[T]) Type() string {
+	return o.Value.Type()
+}
+
+func (o *Optional[T]) CloneValue() (pflag.Value, error) {
+	v, err := cloneValueAs(o.Value)
+	if err != nil {
+		return nil, err
+	}
+	c := *o
+	c.Value = v
+	return &c, nil
+}
+
+func (o *Optional[T]) NoOptDefValue() string {
+	if no, ok := any(o.Value).(NoOptDefValuer); ok {
+		return no.NoOptDefValue()
+	}
+	return ""
+}
+
+func (o *Optional[T]) MarshalYAML() (interface{}, error) {
+	if !o.set {
+		return yaml.Node{
+			Kind:  yaml.ScalarNode,
+			Tag:   "!!null",
+			Value: "null",
+		}, nil
+	}
+	m, ok := any(o.Value).(yaml.Marshaler)
+	if !ok {
+		return o.Value, nil
+	}
+	return m.MarshalYAML()
+}
+
+func (o *Optional[T]) UnmarshalYAML(n *yaml.Node) error {
+	if n.Kind == yaml.ScalarNode && n.ShortTag() == "!!null" {
+		o.Unset()
+		return nil
+	}
+	var err error
+	if um, ok := any(o.Value).(yaml.Unmarshaler); ok {
+		err = um.UnmarshalYAML(n)
+	} else if n.Kind == yaml.ScalarNode {
+		err = o.Value.Set(n.Value)
+	} else {
+		err = n.Decode(o.Value)
+	}
+	if err != nil {
+		return err
+	}
+	o.set = true
+	return nil
+}
+
+func (o *Optional[T]) MarshalJSON() ([]byte, error) {
+	if !o.set {
+		return []byte("null"), nil
+	}
+	return json.Marshal(o.Value)
+}
+
+func (o *Optional[T]) UnmarshalJSON(b []byte) error {
+	if strings.TrimSpace(string(b)) == "null" {
+		o.Unset()
+		return nil
+	}
+	err := json.Unmarshal(b, o.Value)
+	if err != nil {
+		return err
+	}
+	o.set = true
+	return nil
+}
+
+func (o *Optional[T]) Underlying() pflag.Value { return o.Value }
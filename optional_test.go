@@ -0,0 +1,121 @@
+package serpent_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	serpent "github.com/coder/serpent"
+)
+
+func TestOptional(t *testing.T) {
+	t.Parallel()
+
+	cmd := func(replicas *serpent.Optional[*serpent.Int64], debug *serpent.Optional[*serpent.Bool]) *serpent.Command {
+		return &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{
+				{
+					Name:  "replicas",
+					Flag:  "replicas",
+					Env:   "REPLICAS",
+					YAML:  "replicas",
+					Value: replicas,
+				},
+				{
+					Name:    "debug",
+					Flag:    "debug",
+					Default: "false",
+					Value:   debug,
+				},
+			},
+			Handler: func(i *serpent.Invocation) error { return nil },
+		}
+	}
+
+	t.Run("Unset", func(t *testing.T) {
+		t.Parallel()
+		replicas := serpent.OptionalOf(new(serpent.Int64))
+		debug := serpent.OptionalOf(new(serpent.Bool))
+		require.NoError(t, cmd(replicas, debug).Invoke().Run())
+
+		_, ok := replicas.Get()
+		require.False(t, ok)
+		require.Equal(t, serpent.ValueSourceNone, replicas.Source())
+
+		v, ok := debug.Get()
+		require.True(t, ok)
+		require.False(t, v.Value())
+		require.Equal(t, serpent.ValueSourceDefault, debug.Source())
+	})
+
+	t.Run("ExplicitZero", func(t *testing.T) {
+		t.Parallel()
+		replicas := serpent.OptionalOf(new(serpent.Int64))
+		debug := serpent.OptionalOf(new(serpent.Bool))
+		inv := cmd(replicas, debug).Invoke("--debug")
+		inv.Environ.Set("REPLICAS", "0")
+		require.NoError(t, inv.Run())
+
+		v, ok := replicas.Get()
+		require.True(t, ok)
+		require.EqualValues(t, 0, v.Value())
+		require.Equal(t, serpent.ValueSourceEnv, replicas.Source())
+
+		b, ok := debug.Get()
+		require.True(t, ok)
+		require.True(t, b.Value())
+		require.Equal(t, serpent.ValueSourceFlag, debug.Source())
+	})
+
+	t.Run("JSON", func(t *testing.T) {
+		t.Parallel()
+		o := serpent.OptionalOf(new(serpent.Int64))
+		byt, err := json.Marshal(o)
+		require.NoError(t, err)
+		require.Equal(t, "null", string(byt))
+
+		require.NoError(t, json.Unmarshal([]byte("3"), o))
+		v, ok := o.Get()
+		require.True(t, ok)
+		require.EqualValues(t, 3, v.Value())
+
+		byt, err = json.Marshal(o)
+		require.NoError(t, err)
+		require.Equal(t, "3", string(byt))
+
+		require.NoError(t, json.Unmarshal([]byte("null"), o))
+		require.False(t, o.IsSet())
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		t.Parallel()
+		replicas := serpent.OptionalOf(new(serpent.Int64))
+		os := serpent.OptionSet{
+			{Name: "replicas", YAML: "replicas", Value: replicas},
+		}
+
+		n, err := os.MarshalYAML()
+		require.NoError(t, err)
+		byt, err := yaml.Marshal(n)
+		require.NoError(t, err)
+		require.Contains(t, string(byt), "replicas: null")
+
+		var doc yaml.Node
+		require.NoError(t, yaml.Unmarshal([]byte("replicas: 0\n"), &doc))
+		require.NoError(t, os.UnmarshalYAML(&doc))
+		require.NoError(t, os.SetDefaults())
+		v, ok := replicas.Get()
+		require.True(t, ok)
+		require.EqualValues(t, 0, v.Value())
+		require.Equal(t, serpent.ValueSourceYAML, replicas.Source())
+
+		replicas.Unset()
+		os[0].ValueSource = serpent.ValueSourceNone
+		require.NoError(t, yaml.Unmarshal([]byte("replicas: null\n"), &doc))
+		require.NoError(t, os.UnmarshalYAML(&doc))
+		require.False(t, replicas.IsSet())
+	})
+}
@@ -0,0 +1,313 @@
+package serpent
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	home "github.com/mitchellh/go-homedir"
+	"github.com/spf13/pflag"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	_ pflag.Value      = (*FilePath)(nil)
+	_ pflag.Value      = (*DirPath)(nil)
+	_ yaml.Unmarshaler = (*FilePath)(nil)
+	_ yaml.Unmarshaler = (*DirPath)(nil)
+	_ ValueCompleter   = (*FilePath)(nil)
+	_ ValueCompleter   = (*DirPath)(nil)
+)
+
+// pathCheck describes how a path value is expanded and validated.
+type pathCheck struct {
+	dir              bool
+	mustExist        bool
+	relativeToConfig bool
+	perm             os.FileMode
+	maxPerm          os.FileMode
+	extensions       []string
+}
+
+// pathBinding is the invocation state a path value is expanded with.
+type pathBinding struct {
+	environ    Environ
+	bound      bool
+	configPath string
+}
+
+func (b *pathBinding) lookupEnv(name string) (string, bool) {
+	if b.bound {
+		return b.environ.Lookup(name)
+	}
+	return os.LookupEnv(name)
+}
+
+// pathEnvRe matches the environment variables expanded in paths. Only the
+// braced form is expanded, so that a literal "$" in a path, which is valid
+// in file names, is left alone.
+var pathEnvRe = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expand expands a leading "~" and "${VAR}" environment variables in path.
+func (b *pathBinding) expand(path string) (string, error) {
+	path = pathEnvRe.ReplaceAllStringFunc(path, func(match string) string {
+		v, _ := b.lookupEnv(match[2 : len(match)-1])
+		return v
+	})
+	if path != "~" && !strings.HasPrefix(path, "~/") && !strings.HasPrefix(path, "~"+string(os.PathSeparator)) {
+		return path, nil
+	}
+	homeDir, ok := b.lookupEnv("HOME")
+	if !ok || homeDir == "" {
+		var err error
+		homeDir, err = home.Dir()
+		if err != nil {
+			return "", xerrors.Errorf("expand home directory: %w", err)
+		}
+	}
+	return filepath.Join(homeDir, path[1:]), nil
+}
+
+// resolve expands path and validates it against c. If fromConfig is set, a
+// relative path is resolved against the directory of the bound YAML config.
+func (b *pathBinding) resolve(path string, c pathCheck, fromConfig bool) (string, error) {
+	if path == "" {
+		return "", nil
+	}
+	path, err := b.expand(path)
+	if err != nil {
+		return "", err
+	}
+	if fromConfig && c.relativeToConfig && b.configPath != "" && !filepath.IsAbs(path) {
+		path = filepath.Join(filepath.Dir(b.configPath), path)
+	}
+
+	if len(c.extensions) > 0 && !hasExtension(path, c.extensions) {
+		return "", xerrors.Errorf("%s must have one of the extensions %s", path, strings.Join(c.extensions, ", "))
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		if os.IsNotExist(err) && !c.mustExist {
+			return path, nil
+		}
+		if os.IsNotExist(err) {
+			return "", xerrors.Errorf("%s does not exist", path)
+		}
+		return "", xerrors.Errorf("stat %s: %w", path, err)
+	}
+	switch {
+	case c.dir && !info.IsDir():
+		return "", xerrors.Errorf("%s is not a directory", path)
+	case !c.dir && info.IsDir():
+		return "", xerrors.Errorf("%s is a directory", path)
+	}
+	perm := info.Mode().Perm()
+	if perm&c.perm != c.perm {
+		return "", xerrors.Errorf("%s has permissions %v, want at least %v", path, perm, c.perm)
+	}
+	if c.maxPerm != 0 && perm&^c.maxPerm != 0 {
+		return "", xerrors.Errorf("%s has permissions %v, want at most %v", path, perm, c.maxPerm)
+	}
+	return path, nil
+}
+
+func hasExtension(path string, extensions []string) bool {
+	ext := filepath.Ext(path)
+	for _, e := range extensions {
+		if strings.EqualFold(ext, "."+strings.TrimPrefix(e, ".")) {
+			return true
+		}
+	}
+	return false
+}
+
+// FilePath is a path to a regular file. A leading "~" and environment
+// variables written as "${VAR}" are expanded when the value is set, and the
+// file is validated against the checks configured on the value.
+type FilePath struct {
+	Value *string
+	// MustExist requires the file to exist.
+	MustExist bool
+	// RelativeToConfig resolves relative paths read from a YAML config
+	// against the directory of that config file.
+	RelativeToConfig bool
+	// Perm is the set of permission bits the file must have, if it exists.
+	Perm os.FileMode
+	// MaxPerm, if set, rejects files with permission bits outside of it,
+	// e.g. 0o600 for private keys.
+	MaxPerm os.FileMode
+	// Extensions restricts the file to the given extensions, e.g. "yaml".
+	// It also filters completions.
+	Extensions []string
+
+	binding pathBinding
+}
+
+func FilePathOf(v *string) *FilePath {
+	return &FilePath{Value: v}
+}
+
+func (p *FilePath) check() pathCheck {
+	return pathCheck{
+		mustExist:        p.MustExist,
+		relativeToConfig: p.RelativeToConfig,
+		perm:             p.Perm,
+		maxPerm:          p.MaxPerm,
+		extensions:       p.Extensions,
+	}
+}
+
+func (p *FilePath) bindInvocation(inv *Invocation) {
+	p.binding.environ = inv.Environ
+	p.binding.bound = true
+}
+
+func (p *FilePath) bindConfigPath(path string) {
+	p.binding.configPath = path
+}
+
+func (p *FilePath) Set(v string) error {
+	path, err := p.binding.resolve(v, p.check(), false)
+	if err != nil {
+		return err
+	}
+	*p.Value = path
+	return nil
+}
+
+func (p *FilePath) String() string {
+	return *p.Value
+}
+
+func (*FilePath) Type() string {
+	return "file"
+}
+
+func (p *FilePath) CloneValue() (pflag.Value, error) {
+	c := *p
+	c.Value = clonePtr(p.Value)
+	return &c, nil
+}
+
+func (p *FilePath) MarshalYAML() (interface{}, error) {
+	return yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Value: p.String(),
+	}, nil
+}
+
+func (p *FilePath) UnmarshalYAML(n *yaml.Node) error {
+	path, err := p.binding.resolve(n.Value, p.check(), true)
+	if err != nil {
+		return err
+	}
+	*p.Value = path
+	return nil
+}
+
+// CompletionHandler completes files matching Extensions, and directories
+// to descend into.
+func (p *FilePath) CompletionHandler() CompletionHandlerFunc {
+	return func(*Invocation) []string {
+		if len(p.Extensions) == 0 {
+			return []string{DirectiveCompletion(CompletionFileFallback)}
+		}
+		out := make([]string, 0, len(p.Extensions)+1)
+		for _, ext := range p.Extensions {
+			out = append(out, strings.TrimPrefix(ext, "."))
+		}
+		return append(out, DirectiveCompletion(CompletionFilterFileExt))
+	}
+}
+
+// DirPath is a path to a directory. A leading "~" and environment variables
+// written as "${VAR}" are expanded when the value is set, and the directory
+// is validated against the checks configured on the value.
+type DirPath struct {
+	Value *string
+	// MustExist requires the directory to exist.
+	MustExist bool
+	// RelativeToConfig resolves relative paths read from a YAML config
+	// against the directory of that config file.
+	RelativeToConfig bool
+	// Perm is the set of permission bits the directory must have, if it
+	// exists.
+	Perm os.FileMode
+	// MaxPerm, if set, rejects directories with permission bits outside
+	// of it.
+	MaxPerm os.FileMode
+
+	binding pathBinding
+}
+
+func DirPathOf(v *string) *DirPath {
+	return &DirPath{Value: v}
+}
+
+func (p *DirPath) check() pathCheck {
+	return pathCheck{
+		dir:              true,
+		mustExist:        p.MustExist,
+		relativeToConfig: p.RelativeToConfig,
+		perm:             p.Perm,
+		maxPerm:          p.MaxPerm,
+	}
+}
+
+func (p *DirPath) bindInvocation(inv *Invocation) {
+	p.binding.environ = inv.Environ
+	p.binding.bound = true
+}
+
+func (p *DirPath) bindConfigPath(path string) {
+	p.binding.configPath = path
+}
+
+func (p *DirPath) Set(v string) error {
+	path, err := p.binding.resolve(v, p.check(), false)
+	if err != nil {
+		return err
+	}
+	*p.Value = path
+	return nil
+}
+
+func (p *DirPath) String() string {
+	return *p.Value
+}
+
+func (*DirPath) Type() string {
+	return "directory"
+}
+
+func (p *DirPath) CloneValue() (pflag.Value, error) {
+	c := *p
+	c.Value = clonePtr(p.Value)
+	return &c, nil
+}
+
+func (p *DirPath) MarshalYAML() (interface{}, error) {
+	return yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Value: p.String(),
+	}, nil
+}
+
+func (p *DirPath) UnmarshalYAML(n *yaml.Node) error {
+	path, err := p.binding.resolve(n.Value, p.check(), true)
+	if err != nil {
+		return err
+	}
+	*p.Value = path
+	return nil
+}
+
+// CompletionHandler completes directories only.
+func (*DirPath) CompletionHandler() CompletionHandlerFunc {
+	return func(*Invocation) []string {
+		return []string{DirectiveCompletion(CompletionFilterDirs)}
+	}
+}
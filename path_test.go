@@ -0,0 +1,179 @@
+package serpent_test
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	serpent "github.com/coder/serpent"
+)
+
+func TestPathValues(t *testing.T) {
+	t.Parallel()
+
+	t.Run("ExpandHomeAndEnv", func(t *testing.T) {
+		t.Parallel()
+		homeDir := t.TempDir()
+		var cacheDir string
+		cmd := &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{
+				{
+					Name:  "cache-dir",
+					Flag:  "cache-dir",
+					Value: serpent.DirPathOf(&cacheDir),
+				},
+			},
+			Handler: func(i *serpent.Invocation) error { return nil },
+		}
+
+		inv := cmd.Invoke("--cache-dir", "~/${APP}/cache")
+		inv.Environ.Set("HOME", homeDir)
+		inv.Environ.Set("APP", "myapp")
+		require.NoError(t, inv.Run())
+		require.Equal(t, filepath.Join(homeDir, "myapp", "cache"), cacheDir)
+
+		// Unbraced dollar signs are literal.
+		inv = cmd.Invoke("--cache-dir", "/tmp/$APP/price$5")
+		inv.Environ.Set("APP", "myapp")
+		require.NoError(t, inv.Run())
+		require.Equal(t, "/tmp/$APP/price$5", cacheDir)
+	})
+
+	t.Run("MustExist", func(t *testing.T) {
+		t.Parallel()
+		var path string
+		v := serpent.FilePathOf(&path)
+		v.MustExist = true
+
+		err := v.Set(filepath.Join(t.TempDir(), "missing"))
+		require.ErrorContains(t, err, "does not exist")
+
+		existing := filepath.Join(t.TempDir(), "cert.pem")
+		require.NoError(t, os.WriteFile(existing, nil, 0o600))
+		require.NoError(t, v.Set(existing))
+		require.Equal(t, existing, path)
+	})
+
+	t.Run("Type", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		file := filepath.Join(dir, "file")
+		require.NoError(t, os.WriteFile(file, nil, 0o600))
+
+		var path string
+		require.ErrorContains(t, serpent.FilePathOf(&path).Set(dir), "is a directory")
+		require.ErrorContains(t, serpent.DirPathOf(&path).Set(file), "is not a directory")
+	})
+
+	t.Run("Permissions", func(t *testing.T) {
+		t.Parallel()
+		file := filepath.Join(t.TempDir(), "key")
+		require.NoError(t, os.WriteFile(file, nil, 0o644))
+		require.NoError(t, os.Chmod(file, 0o644))
+
+		var path string
+		v := serpent.FilePathOf(&path)
+		v.MaxPerm = 0o600
+		require.ErrorContains(t, v.Set(file), "want at most")
+
+		v = serpent.FilePathOf(&path)
+		v.Perm = 0o100
+		require.ErrorContains(t, v.Set(file), "want at least")
+
+		v = serpent.FilePathOf(&path)
+		v.Perm = 0o400
+		v.MaxPerm = 0o644
+		require.NoError(t, v.Set(file))
+	})
+
+	t.Run("Extensions", func(t *testing.T) {
+		t.Parallel()
+		var path string
+		v := serpent.FilePathOf(&path)
+		v.Extensions = []string{"yaml", ".yml"}
+		require.NoError(t, v.Set("config.YAML"))
+		require.NoError(t, v.Set("config.yml"))
+		require.ErrorContains(t, v.Set("config.json"), "must have one of the extensions")
+	})
+
+	t.Run("RelativeToConfig", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		configPath := filepath.Join(dir, "config.yaml")
+		require.NoError(t, os.WriteFile(configPath, []byte("cert: certs/tls.pem\nkey: certs/tls.key\n"), 0o600))
+
+		var (
+			config serpent.YAMLConfigPath
+			cert   string
+			key    string
+		)
+		certValue := serpent.FilePathOf(&cert)
+		certValue.RelativeToConfig = true
+		cmd := &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{
+				{Name: "config", Flag: "config", Value: &config},
+				{Name: "cert", Flag: "cert", YAML: "cert", Value: certValue},
+				{Name: "key", Flag: "key", YAML: "key", Value: serpent.FilePathOf(&key)},
+			},
+			Handler: func(i *serpent.Invocation) error { return nil },
+		}
+		require.NoError(t, cmd.Invoke("--config", configPath).Run())
+		require.Equal(t, filepath.Join(dir, "certs", "tls.pem"), cert)
+		require.Equal(t, filepath.Join("certs", "tls.key"), key)
+	})
+
+	t.Run("Completion", func(t *testing.T) {
+		t.Parallel()
+		dir := t.TempDir()
+		require.NoError(t, os.Mkdir(filepath.Join(dir, "sub"), 0o755))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "a.yaml"), nil, 0o600))
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "b.json"), nil, 0o600))
+
+		var (
+			file string
+			out  string
+		)
+		fileValue := serpent.FilePathOf(&file)
+		fileValue.Extensions = []string{"yaml"}
+		cmd := func() *serpent.Command {
+			return &serpent.Command{
+				Use: "root",
+				Options: serpent.OptionSet{
+					{Name: "file", Flag: "file", Value: fileValue},
+					{Name: "out", Flag: "out", Value: serpent.DirPathOf(&out)},
+				},
+				Handler: func(i *serpent.Invocation) error { return nil },
+			}
+		}
+
+		prefix := dir + string(os.PathSeparator)
+		i := cmd().Invoke("--file", prefix)
+		i.Environ.Set(serpent.CompletionModeEnv, "1")
+		io := fakeIO(i)
+		require.NoError(t, i.Run())
+		require.ElementsMatch(t, []string{
+			prefix + "a.yaml",
+			fmt.Sprintf("%ssub%c", prefix, os.PathSeparator),
+		}, splitLines(io.Stdout.String()))
+
+		i = cmd().Invoke("--out", prefix)
+		i.Environ.Set(serpent.CompletionModeEnv, "1")
+		io = fakeIO(i)
+		require.NoError(t, i.Run())
+		require.Equal(t, fmt.Sprintf("%ssub%c\n", prefix, os.PathSeparator), io.Stdout.String())
+	})
+}
+
+func splitLines(s string) []string {
+	lines := strings.Split(s, "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
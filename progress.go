@@ -0,0 +1,262 @@
+package serpent
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/mattn/go-runewidth"
+)
+
+const (
+	defaultProgressInterval      = 100 * time.Millisecond
+	defaultProgressPlainInterval = 5 * time.Second
+)
+
+// spinnerFrames are drawn in turn by spinners on a terminal.
+var spinnerFrames = []string{"|", "/", "-", `\`}
+
+// ProgressOptions configures Progress.
+type ProgressOptions struct {
+	// Label describes the operation, e.g. "Uploading".
+	Label string
+	// Total is the amount of work to do, e.g. a number of bytes. If zero,
+	// a spinner is shown instead of a bar.
+	Total int64
+	// Interval is how often the progress is redrawn on a terminal. If
+	// zero, it's 100ms.
+	Interval time.Duration
+	// PlainInterval is how often a line reporting the progress is written
+	// when Stderr isn't a terminal. If zero, it's 5s.
+	PlainInterval time.Duration
+}
+
+// ProgressReporter reports the progress of an operation on the Stderr of
+// the invocation that started it. It's safe for concurrent use.
+//
+// The reporter writes to Stderr from its own goroutine until it's done, so
+// a handler that writes to Stderr meanwhile needs it to be safe for
+// concurrent writes, as os.Stderr is, and its output may be mixed with the
+// progress. The writes of the reporters of an invocation are serialized.
+type ProgressReporter struct {
+	opts    ProgressOptions
+	inv     *Invocation
+	tty     bool
+	start   time.Time
+	current atomic.Int64
+	// last is the line last drawn on the terminal.
+	last string
+	// writeMu serializes the writes of the invocation's reporters.
+	writeMu *sync.Mutex
+
+	stop     chan struct{}
+	stopOnce sync.Once
+	// reason is what stopped the reporter, written before stop is closed.
+	reason string
+	done   chan struct{}
+}
+
+// progressReporters tracks the reporters started by a run, so that the
+// run stops them before it returns.
+type progressReporters struct {
+	mu        sync.Mutex
+	reporters []*ProgressReporter
+	writeMu   sync.Mutex
+}
+
+func (r *progressReporters) add(p *ProgressReporter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reporters = append(r.reporters, p)
+}
+
+// stop stops the reporters that aren't done, and waits for them to write
+// their final state.
+func (r *progressReporters) stop() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	reporters := r.reporters
+	r.reporters = nil
+	r.mu.Unlock()
+	for _, p := range reporters {
+		p.end("stopped")
+	}
+}
+
+// Progress starts reporting the progress of an operation on Stderr, until
+// Done is called or the invocation is canceled. Reporters that are still
+// running when the handler returns are stopped before Run returns.
+//
+// If Stderr is a terminal, a bar, or a spinner if there's no total, is
+// redrawn in place. Otherwise, a plain line is written periodically, so
+// that logs show the operation is alive. Nothing is written if the user
+// asked for machine-readable output, with --output json or yaml.
+func (inv *Invocation) Progress(opts ProgressOptions) *ProgressReporter {
+	if opts.Interval <= 0 {
+		opts.Interval = defaultProgressInterval
+	}
+	if opts.PlainInterval <= 0 {
+		opts.PlainInterval = defaultProgressPlainInterval
+	}
+	p := &ProgressReporter{
+		opts:  opts,
+		inv:   inv,
+		tty:   inv.stderrTerminal().IsTTY(),
+		start: time.Now(),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	if inv.machineOutput() {
+		close(p.done)
+		return p
+	}
+	p.writeMu = new(sync.Mutex)
+	if inv.progress != nil {
+		p.writeMu = &inv.progress.writeMu
+		inv.progress.add(p)
+	}
+	go p.run()
+	return p
+}
+
+// Spinner starts reporting an operation whose amount of work isn't known.
+// It's Progress without a total.
+func (inv *Invocation) Spinner(label string) *ProgressReporter {
+	return inv.Progress(ProgressOptions{Label: label})
+}
+
+// machineOutput reports whether the user asked for the output to be in a
+// machine-readable format, with the option of a Formatter.
+func (inv *Invocation) machineOutput() bool {
+	opt := inv.Options().ByName("output")
+	if opt == nil {
+		return false
+	}
+	switch opt.Value.String() {
+	case OutputJSON, OutputYAML:
+		return true
+	}
+	return false
+}
+
+// Add adds n to the work done.
+func (p *ProgressReporter) Add(n int64) {
+	p.current.Add(n)
+}
+
+// Set sets the work done to n.
+func (p *ProgressReporter) Set(n int64) {
+	p.current.Store(n)
+}
+
+// Write adds len(b) to the work done, so that the progress of a copy can
+// be reported with io.TeeReader or io.MultiWriter.
+func (p *ProgressReporter) Write(b []byte) (int, error) {
+	p.Add(int64(len(b)))
+	return len(b), nil
+}
+
+// Done stops reporting the progress and writes its final state. It's safe
+// to call more than once, and after the invocation is canceled.
+func (p *ProgressReporter) Done() {
+	p.end("done")
+}
+
+// end stops the reporter for the given reason, unless it's already
+// stopped, and waits for it to write its final state.
+func (p *ProgressReporter) end(reason string) {
+	p.stopOnce.Do(func() {
+		p.reason = reason
+		close(p.stop)
+	})
+	<-p.done
+}
+
+func (p *ProgressReporter) run() {
+	defer close(p.done)
+
+	interval := p.opts.PlainInterval
+	if p.tty {
+		interval = p.opts.Interval
+		p.draw(0)
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	ctx := p.inv.Context()
+	for frame := 1; ; frame++ {
+		select {
+		case <-ctx.Done():
+			p.finish("canceled")
+			return
+		case <-p.stop:
+			reason := p.reason
+			if reason != "done" && ctx.Err() != nil {
+				// The run stopped the reporter as it was canceled.
+				reason = "canceled"
+			}
+			p.finish(reason)
+			return
+		case <-ticker.C:
+			if p.tty {
+				p.draw(frame)
+			} else {
+				p.printf("%s: %s\n", p.opts.Label, p.status())
+			}
+		}
+	}
+}
+
+// draw redraws the progress in place on the terminal.
+func (p *ProgressReporter) draw(frame int) {
+	var line string
+	if p.opts.Total <= 0 {
+		line = fmt.Sprintf("%s %s %s", spinnerFrames[frame%len(spinnerFrames)], p.opts.Label, p.status())
+	} else {
+		status := p.status()
+		// The bar takes the room left on the line, within reason.
+		width := p.inv.stderrTerminal().Width() - runewidth.StringWidth(p.opts.Label) - len(status) - 5
+		width = min(max(width, 10), 40)
+		filled := int(float64(width) * p.fraction())
+		line = fmt.Sprintf("%s [%s%s] %s", p.opts.Label,
+			strings.Repeat("=", filled), strings.Repeat(" ", width-filled), status)
+	}
+	if line == p.last {
+		return
+	}
+	p.last = line
+	// Clear what's left of the previous line.
+	p.printf("\r%s\x1b[K", line)
+}
+
+// finish writes the final state of the progress, described by what.
+func (p *ProgressReporter) finish(what string) {
+	prefix := ""
+	if p.tty {
+		prefix = "\r\x1b[K"
+	}
+	p.printf("%s%s: %s, %s\n", prefix, p.opts.Label, what, p.status())
+}
+
+func (p *ProgressReporter) printf(format string, args ...any) {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, _ = fmt.Fprintf(p.inv.Stderr, format, args...)
+}
+
+// status describes the work done, or the time elapsed for spinners.
+func (p *ProgressReporter) status() string {
+	if p.opts.Total <= 0 {
+		return fmt.Sprintf("%s elapsed", time.Since(p.start).Round(100*time.Millisecond))
+	}
+	return fmt.Sprintf("%d%% (%d/%d)", int(p.fraction()*100), p.current.Load(), p.opts.Total)
+}
+
+func (p *ProgressReporter) fraction() float64 {
+	f := float64(p.current.Load()) / float64(p.opts.Total)
+	return min(max(f, 0), 1)
+}
@@ -0,0 +1,170 @@
+package serpent_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	serpent "github.com/coder/serpent"
+	"github.com/coder/serpent/serpenttest"
+)
+
+func progressCommand(wait time.Duration) *serpent.Command {
+	f := serpent.NewFormatter(formatRow{})
+	return &serpent.Command{
+		Use:     "upload",
+		Options: f.Options(),
+		Handler: func(inv *serpent.Invocation) error {
+			p := inv.Progress(serpent.ProgressOptions{
+				Label:         "Uploading",
+				Total:         100,
+				Interval:      time.Millisecond,
+				PlainInterval: time.Millisecond,
+			})
+			_, _ = p.Write(make([]byte, 50))
+			time.Sleep(wait)
+			p.Add(50)
+			p.Done()
+			return f.Format(inv, []formatRow{{Name: "file"}})
+		},
+	}
+}
+
+func TestProgress(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Plain", func(t *testing.T) {
+		t.Parallel()
+
+		res := serpenttest.Run(t, progressCommand(50*time.Millisecond), serpenttest.RunOptions{})
+		require.NoError(t, res.Err)
+		require.Contains(t, res.Stderr, "Uploading: 50% (50/100)\n")
+		require.True(t, strings.HasSuffix(res.Stderr, "Uploading: done, 100% (100/100)\n"), res.Stderr)
+		require.NotContains(t, res.Stderr, "\r")
+	})
+
+	t.Run("Terminal", func(t *testing.T) {
+		t.Parallel()
+
+		inv := progressCommand(50 * time.Millisecond).Invoke().
+			WithTerminal(serpent.StaticTerminal{TTY: true, Columns: 40})
+		io := fakeIO(inv)
+		require.NoError(t, inv.Run())
+		// Unchanged progress isn't redrawn.
+		require.Equal(t, 1, strings.Count(io.Stderr.String(), "\rUploading [=======       ] 50% (50/100)\x1b[K"), io.Stderr.String())
+		require.True(t, strings.HasSuffix(io.Stderr.String(), "\r\x1b[KUploading: done, 100% (100/100)\n"), io.Stderr.String())
+	})
+
+	t.Run("WideLabel", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := &serpent.Command{
+			Use: "upload",
+			Handler: func(inv *serpent.Invocation) error {
+				p := inv.Progress(serpent.ProgressOptions{Label: "アップロード", Total: 100})
+				p.Set(50)
+				p.Done()
+				return nil
+			},
+		}
+		inv := cmd.Invoke().WithTerminal(serpent.StaticTerminal{TTY: true, Columns: 40})
+		io := fakeIO(inv)
+		require.NoError(t, inv.Run())
+		// The label takes two columns per character.
+		require.Regexp(t, `\rアップロード \[[= ]{11}\] `, io.Stderr.String())
+	})
+
+	t.Run("MachineOutput", func(t *testing.T) {
+		t.Parallel()
+
+		for _, format := range []string{"json", "yaml"} {
+			res := serpenttest.Run(t, progressCommand(10*time.Millisecond), serpenttest.RunOptions{
+				Args: []string{"--output", format},
+			})
+			require.NoError(t, res.Err)
+			require.Contains(t, res.Stdout, "file")
+			require.Empty(t, res.Stderr)
+		}
+	})
+
+	t.Run("Spinner", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := &serpent.Command{
+			Use: "build",
+			Handler: func(inv *serpent.Invocation) error {
+				inv.Spinner("Building").Done()
+				return nil
+			},
+		}
+		inv := cmd.Invoke().WithTerminal(serpent.StaticTerminal{TTY: true})
+		io := fakeIO(inv)
+		require.NoError(t, inv.Run())
+		require.True(t, strings.HasPrefix(io.Stderr.String(), "\r| Building 0s elapsed\x1b[K"), io.Stderr.String())
+		require.Contains(t, io.Stderr.String(), "\r\x1b[KBuilding: done, ")
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cmd := &serpent.Command{
+			Use: "upload",
+			Handler: func(inv *serpent.Invocation) error {
+				p := inv.Progress(serpent.ProgressOptions{Label: "Uploading", Total: 4})
+				p.Set(1)
+				cancel()
+				p.Done()
+				p.Done()
+				return nil
+			},
+		}
+		inv := cmd.Invoke().WithContext(ctx)
+		io := fakeIO(inv)
+		require.NoError(t, inv.Run())
+		// Either Done or the cancellation may be noticed first.
+		require.Regexp(t, `^Uploading: (done|canceled), 25% \(1/4\)\n$`, io.Stderr.String())
+	})
+
+	t.Run("NotDone", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		cmd := &serpent.Command{
+			Use: "upload",
+			Handler: func(inv *serpent.Invocation) error {
+				inv.Progress(serpent.ProgressOptions{Label: "Uploading", Total: 4}).Set(1)
+				inv.Spinner("Building")
+				cancel()
+				return nil
+			},
+		}
+		inv := cmd.Invoke().WithContext(ctx)
+		io := fakeIO(inv)
+		require.NoError(t, inv.Run())
+		// Run waits for the reporters to write their final state.
+		require.Contains(t, io.Stderr.String(), "Uploading: canceled, 25% (1/4)\n")
+		require.Contains(t, io.Stderr.String(), "Building: canceled, ")
+	})
+
+	t.Run("Stopped", func(t *testing.T) {
+		t.Parallel()
+
+		cmd := &serpent.Command{
+			Use: "upload",
+			Handler: func(inv *serpent.Invocation) error {
+				inv.Progress(serpent.ProgressOptions{Label: "Uploading", Total: 4}).Set(2)
+				return nil
+			},
+		}
+		inv := cmd.Invoke()
+		io := fakeIO(inv)
+		require.NoError(t, inv.Run())
+		require.Equal(t, "Uploading: stopped, 50% (2/4)\n", io.Stderr.String())
+	})
+}
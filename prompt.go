@@ -0,0 +1,345 @@
+package serpent
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+	"golang.org/x/xerrors"
+)
+
+// ErrNotInteractive is returned by prompts when the invocation's Stdin
+// isn't a terminal, so that nobody could answer them.
+var ErrNotInteractive = xerrors.New("stdin is not a terminal")
+
+// YesOption returns a --yes option that makes Confirm accept without
+// asking. Add it to the root command's options to make it apply to every
+// command, including in scripts without a terminal.
+func YesOption() Option {
+	var yes bool
+	return Option{
+		Name:          "yes",
+		Flag:          "yes",
+		FlagShorthand: "y",
+		Description:   "Bypass confirmation prompts.",
+		Value:         BoolOf(&yes),
+	}
+}
+
+// PromptOptions configures Prompt.
+type PromptOptions struct {
+	// Text is the question asked, e.g. "Workspace name".
+	Text string
+	// Default is the answer if the user enters an empty line. It's shown
+	// in the prompt unless the input is secret.
+	Default string
+	// Secret hides the input, e.g. for passwords. It's only hidden if
+	// Stdin is a terminal: with WithInteractiveStdin, the input is read as
+	// a plain line, and shown if whatever writes it echoes it.
+	Secret bool
+	// Validate checks the answer. If it returns an error, the error is
+	// printed and the question asked again.
+	Validate func(answer string) error
+}
+
+// WithInteractiveStdin returns a copy of the invocation whose prompts read
+// Stdin as if it were a terminal, even if it isn't one. It's useful to
+// script prompts in tests. Secret input is read as plain lines, so it
+// isn't hidden.
+func (inv *Invocation) WithInteractiveStdin() *Invocation {
+	return inv.with(func(i *Invocation) {
+		i.interactiveStdin = true
+	})
+}
+
+// IsInteractive reports whether a user can answer prompts, that is whether
+// Stdin is a terminal or WithInteractiveStdin was used.
+func (inv *Invocation) IsInteractive() bool {
+	if inv.interactiveStdin {
+		return true
+	}
+	_, ok := terminalFd(inv.Stdin)
+	return ok
+}
+
+// Prompt asks a question on Stderr and reads the answer from a line of
+// Stdin. It returns ErrNotInteractive if Stdin isn't a terminal, and the
+// context's error if the invocation is canceled while waiting.
+func (inv *Invocation) Prompt(opts PromptOptions) (string, error) {
+	if !inv.IsInteractive() {
+		return "", xerrors.Errorf("prompt %q: %w", opts.Text, ErrNotInteractive)
+	}
+
+	text := opts.Text
+	if opts.Default != "" && !opts.Secret {
+		text += " [" + opts.Default + "]"
+	}
+	for {
+		_, _ = fmt.Fprintf(inv.Stderr, "%s: ", text)
+		answer, err := inv.readAnswer(opts.Secret)
+		if err != nil {
+			return "", xerrors.Errorf("prompt %q: %w", opts.Text, err)
+		}
+		if answer == "" {
+			answer = opts.Default
+		}
+		if opts.Validate != nil {
+			if err := opts.Validate(answer); err != nil {
+				_, _ = fmt.Fprintf(inv.Stderr, "%s %v\n", prettyHeader(inv.stderrTerminal().ColorProfile(), "error"), err)
+				continue
+			}
+		}
+		return answer, nil
+	}
+}
+
+// Confirm asks a yes or no question, and returns def if the user enters an
+// empty line. It returns true without asking if the invocation's "yes"
+// option, such as YesOption, is set.
+func (inv *Invocation) Confirm(text string, def bool) (bool, error) {
+	yes := inv.Options().ByName("yes")
+	if yes != nil && yes.Value.String() == "true" {
+		return true, nil
+	}
+
+	choices, defAnswer := "y/N", "n"
+	if def {
+		choices, defAnswer = "Y/n", "y"
+	}
+	// The default isn't passed to Prompt, as the choices already show it.
+	answer, err := inv.Prompt(PromptOptions{
+		Text: text + " (" + choices + ")",
+		Validate: func(answer string) error {
+			switch strings.ToLower(answer) {
+			case "", "y", "yes", "n", "no":
+				return nil
+			}
+			return xerrors.New("please answer yes or no")
+		},
+	})
+	if errors.Is(err, ErrNotInteractive) && yes != nil && yes.Flag != "" {
+		return false, xerrors.Errorf("%w, use --%s to confirm", err, yes.Flag)
+	}
+	if err != nil {
+		return false, err
+	}
+	if answer == "" {
+		answer = defAnswer
+	}
+	return strings.HasPrefix(strings.ToLower(answer), "y"), nil
+}
+
+// Select asks the user to choose one of choices, by number or by name, and
+// returns def if the user enters an empty line.
+func (inv *Invocation) Select(text string, choices []string, def string) (string, error) {
+	details := make([]EnumChoice, 0, len(choices))
+	for _, c := range choices {
+		details = append(details, EnumChoice{Value: c})
+	}
+	return inv.selectChoice(text, details, def)
+}
+
+// selectChoice is Select for choices that may have descriptions, which are
+// listed next to them.
+func (inv *Invocation) selectChoice(text string, choices []EnumChoice, def string) (string, error) {
+	if len(choices) == 0 {
+		return "", xerrors.Errorf("select %q: no choices", text)
+	}
+	if !inv.IsInteractive() {
+		return "", xerrors.Errorf("select %q: %w", text, ErrNotInteractive)
+	}
+
+	width := 0
+	for _, c := range choices {
+		if c.Description != "" {
+			width = max(width, len(c.Value))
+		}
+	}
+	_, _ = fmt.Fprintf(inv.Stderr, "%s:\n", text)
+	for i, c := range choices {
+		if c.Description == "" {
+			_, _ = fmt.Fprintf(inv.Stderr, "  %d) %s\n", i+1, c.Value)
+			continue
+		}
+		_, _ = fmt.Fprintf(inv.Stderr, "  %d) %s%s  %s\n", i+1, c.Value, strings.Repeat(" ", width-len(c.Value)), c.Description)
+	}
+	var chosen string
+	_, err := inv.Prompt(PromptOptions{
+		Text:    fmt.Sprintf("Choose 1-%d", len(choices)),
+		Default: def,
+		Validate: func(answer string) error {
+			if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(choices) {
+				chosen = choices[n-1].Value
+				return nil
+			}
+			for _, c := range choices {
+				if c.Value == answer {
+					chosen = c.Value
+					return nil
+				}
+			}
+			return xerrors.Errorf("%q is not one of the choices", answer)
+		},
+	})
+	return chosen, err
+}
+
+// readAnswer reads a line from Stdin, without its line ending.
+func (inv *Invocation) readAnswer(secret bool) (string, error) {
+	read := scanLine
+	if fd, ok := terminalFd(inv.Stdin); ok && secret {
+		read = func(io.Reader) (string, error) {
+			byt, err := term.ReadPassword(fd)
+			// The user's newline wasn't echoed.
+			_, _ = fmt.Fprintln(inv.Stderr)
+			return string(byt), err
+		}
+	}
+	lines := inv.promptStdin
+	if lines == nil {
+		lines = &lineReader{r: inv.Stdin}
+	}
+	line, err := lines.readLine(inv.Context(), read)
+	if err != nil && inv.Context().Err() != nil {
+		_, _ = fmt.Fprintln(inv.Stderr)
+	}
+	return line, err
+}
+
+// lineReader reads the lines of a reader for prompts. A read can't be
+// interrupted, so it's abandoned if the prompt is canceled, but it stays
+// pending: its line is returned by the next read rather than lost.
+type lineReader struct {
+	r io.Reader
+
+	mu      sync.Mutex
+	pending chan lineResult
+}
+
+type lineResult struct {
+	line string
+	err  error
+}
+
+// readLine returns the pending line, or reads one with read, unless ctx is
+// done first.
+func (l *lineReader) readLine(ctx context.Context, read func(io.Reader) (string, error)) (string, error) {
+	l.mu.Lock()
+	if l.pending == nil {
+		pending := make(chan lineResult, 1)
+		go func() {
+			line, err := read(l.r)
+			pending <- lineResult{line, err}
+		}()
+		l.pending = pending
+	}
+	pending := l.pending
+	l.mu.Unlock()
+
+	select {
+	case <-ctx.Done():
+		return "", ctx.Err()
+	case res := <-pending:
+		l.mu.Lock()
+		l.pending = nil
+		l.mu.Unlock()
+		return res.line, res.err
+	}
+}
+
+// scanLine reads a line from r, without its line ending. The line is read
+// a byte at a time so that nothing following it is consumed, for the next
+// prompt or the command to read.
+func scanLine(r io.Reader) (string, error) {
+	var (
+		line []byte
+		b    = make([]byte, 1)
+	)
+	for {
+		n, err := r.Read(b)
+		if n > 0 {
+			if b[0] == '\n' {
+				break
+			}
+			line = append(line, b[0])
+		}
+		if errors.Is(err, io.EOF) && len(line) > 0 {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+	}
+	return strings.TrimSuffix(string(line), "\r"), nil
+}
+
+// AnnotationSecret marks an option whose value is secret, such as a
+// password, so that its input is hidden when it's prompted for.
+const AnnotationSecret = "secret"
+
+// WithPromptForMissingOptions returns a copy of the invocation that prompts
+// for the required options that weren't given, instead of failing, if
+// Stdin is a terminal. Enum options are prompted with Select, and the
+// values entered have the source ValueSourcePrompt.
+func (inv *Invocation) WithPromptForMissingOptions() *Invocation {
+	return inv.with(func(i *Invocation) {
+		i.promptMissing = true
+	})
+}
+
+// promptOption asks the user for the value of opt.
+func (inv *Invocation) promptOption(opt *Option) error {
+	name := opt.Flag
+	if name == "" {
+		name = opt.Name
+	}
+	if opt.Description != "" {
+		_, _ = fmt.Fprintln(inv.Stderr, opt.Description)
+	}
+
+	if e, ok := opt.Value.(*Enum); ok {
+		choice, err := inv.selectChoice(name, visibleEnumChoices(e.Choices, e.Details), "")
+		if err != nil {
+			return err
+		}
+		err = e.Set(choice)
+		if err != nil {
+			return err
+		}
+	} else {
+		_, err := inv.Prompt(PromptOptions{
+			Text:   name + " " + strings.ReplaceAll(opt.Value.Type(), `\|`, "|"),
+			Secret: opt.Annotations.IsSet(AnnotationSecret),
+			Validate: func(answer string) error {
+				if answer == "" {
+					return xerrors.New("a value is required")
+				}
+				// Slices append what's set, including the values of
+				// the answers that failed.
+				if sv, ok := opt.Value.(pflag.SliceValue); ok {
+					if err := sv.Replace(nil); err != nil {
+						return err
+					}
+				}
+				return opt.Value.Set(answer)
+			},
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	opt.ValueSource = ValueSourcePrompt
+	walkValue(opt.Value, func(v pflag.Value) {
+		if r, ok := v.(valueSourceRecorder); ok {
+			r.recordValueSource(opt.ValueSource)
+		}
+	})
+	return nil
+}
@@ -0,0 +1,376 @@
+package serpent_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	serpent "github.com/coder/serpent"
+	"github.com/coder/serpent/serpenttest"
+)
+
+func promptCommand() *serpent.Command {
+	return &serpent.Command{
+		Use:     "prog",
+		Options: serpent.OptionSet{serpent.YesOption()},
+		Children: []*serpent.Command{
+			{
+				Use: "delete",
+				Handler: func(inv *serpent.Invocation) error {
+					ok, err := inv.Confirm("Delete 3 workspaces?", false)
+					if err != nil {
+						return err
+					}
+					if ok {
+						_, _ = fmt.Fprintln(inv.Stdout, "deleted")
+					} else {
+						_, _ = fmt.Fprintln(inv.Stdout, "kept")
+					}
+					return nil
+				},
+			},
+			{
+				Use: "login",
+				Handler: func(inv *serpent.Invocation) error {
+					user, err := inv.Prompt(serpent.PromptOptions{
+						Text: "Username",
+						Validate: func(answer string) error {
+							if answer == "" {
+								return fmt.Errorf("username is required")
+							}
+							return nil
+						},
+					})
+					if err != nil {
+						return err
+					}
+					password, err := inv.Prompt(serpent.PromptOptions{Text: "Password", Secret: true})
+					if err != nil {
+						return err
+					}
+					region, err := inv.Select("Region", []string{"us", "eu"}, "eu")
+					if err != nil {
+						return err
+					}
+					_, _ = fmt.Fprintf(inv.Stdout, "%s:%d:%s\n", user, len(password), region)
+					return nil
+				},
+			},
+		},
+	}
+}
+
+func TestPrompt(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		name        string
+		args        []string
+		stdin       string
+		interactive bool
+		stdout      string
+		stderr      string
+		err         string
+	}{
+		{
+			name:        "Confirm",
+			args:        []string{"delete"},
+			stdin:       "y\n",
+			interactive: true,
+			stdout:      "deleted\n",
+			stderr:      "Delete 3 workspaces? (y/N): ",
+		},
+		{
+			name:        "ConfirmDefault",
+			args:        []string{"delete"},
+			stdin:       "\n",
+			interactive: true,
+			stdout:      "kept\n",
+		},
+		{
+			name:        "ConfirmInvalid",
+			args:        []string{"delete"},
+			stdin:       "maybe\nYes\n",
+			interactive: true,
+			stdout:      "deleted\n",
+			stderr:      "please answer yes or no",
+		},
+		{
+			name:   "Yes",
+			args:   []string{"--yes", "delete"},
+			stdout: "deleted\n",
+		},
+		{
+			name: "NotInteractive",
+			args: []string{"delete"},
+			err:  "stdin is not a terminal, use --yes to confirm",
+		},
+		{
+			name:        "Login",
+			args:        []string{"login"},
+			stdin:       "\nbob\r\nhunter2\n1\n",
+			interactive: true,
+			stdout:      "bob:7:us\n",
+			stderr:      "username is required",
+		},
+		{
+			name:        "SelectByName",
+			args:        []string{"login"},
+			stdin:       "bob\n\nasia\neu",
+			interactive: true,
+			stdout:      "bob:0:eu\n",
+			stderr:      "\"asia\" is not one of the choices",
+		},
+		{
+			name:        "SelectDefault",
+			args:        []string{"login"},
+			stdin:       "bob\nhunter2\n\n",
+			interactive: true,
+			stdout:      "bob:7:eu\n",
+			stderr:      "  1) us\n  2) eu\nChoose 1-2 [eu]: ",
+		},
+		{
+			name:        "EOF",
+			args:        []string{"login"},
+			stdin:       "bob\n",
+			interactive: true,
+			err:         "EOF",
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+
+			res := serpenttest.Run(t, promptCommand(), serpenttest.RunOptions{
+				Args:        tc.args,
+				Stdin:       tc.stdin,
+				Interactive: tc.interactive,
+			})
+			if tc.err != "" {
+				require.ErrorContains(t, res.Err, tc.err)
+				return
+			}
+			require.NoError(t, res.Err)
+			require.Equal(t, tc.stdout, res.Stdout)
+			require.Contains(t, res.Stderr, tc.stderr)
+		})
+	}
+
+	t.Run("NotInteractiveError", func(t *testing.T) {
+		t.Parallel()
+
+		res := serpenttest.Run(t, promptCommand(), serpenttest.RunOptions{Args: []string{"login"}})
+		require.ErrorIs(t, res.Err, serpent.ErrNotInteractive)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		t.Parallel()
+
+		ctx, cancel := context.WithCancel(context.Background())
+		stdin, w := io.Pipe()
+		defer w.Close()
+		stderr, stderrW := io.Pipe()
+		inv := promptCommand().Invoke("delete").WithContext(ctx).WithInteractiveStdin()
+		inv.Stdin = stdin
+		inv.Stdout = io.Discard
+		inv.Stderr = stderrW
+
+		done := make(chan error, 1)
+		go func() {
+			done <- inv.Run()
+		}()
+		// Wait for the question, then let the command write freely.
+		buf := make([]byte, len("Delete 3 workspaces? (y/N): "))
+		_, err := io.ReadFull(stderr, buf)
+		require.NoError(t, err)
+		require.Equal(t, "Delete 3 workspaces? (y/N): ", string(buf))
+		go func() {
+			_, _ = io.Copy(io.Discard, stderr)
+		}()
+		cancel()
+		require.ErrorIs(t, <-done, context.Canceled)
+	})
+
+	t.Run("CanceledKeepsLine", func(t *testing.T) {
+		t.Parallel()
+
+		stdin, w := io.Pipe()
+		defer w.Close()
+		cmd := &serpent.Command{
+			Use: "prog",
+			Handler: func(inv *serpent.Invocation) error {
+				ctx, cancel := context.WithCancel(inv.Context())
+				cancel()
+				_, err := inv.WithContext(ctx).Prompt(serpent.PromptOptions{Text: "First"})
+				require.ErrorIs(t, err, context.Canceled)
+
+				// The line read for the canceled prompt answers the next.
+				go func() {
+					_, _ = io.WriteString(w, "answer\n")
+				}()
+				answer, err := inv.Prompt(serpent.PromptOptions{Text: "Second"})
+				if err != nil {
+					return err
+				}
+				_, _ = fmt.Fprintln(inv.Stdout, answer)
+				return nil
+			},
+		}
+		inv := cmd.Invoke().WithInteractiveStdin()
+		io := fakeIO(inv)
+		inv.Stdin = stdin
+		require.NoError(t, inv.Run())
+		require.Equal(t, "answer\n", io.Stdout.String())
+	})
+
+	t.Run("Terminal", func(t *testing.T) {
+		t.Parallel()
+
+		pty := serpenttest.StartPTY(t, promptCommand(), serpenttest.PTYOptions{
+			Args: []string{"login"},
+		})
+		pty.ExpectString("Username: ")
+		pty.WriteLine("bob")
+		pty.ExpectString("Password: ")
+		pty.WriteLine("hunter2")
+		pty.ExpectString("Choose 1-2 [eu]: ")
+		pty.WriteLine("2")
+		pty.ExpectString("bob:7:eu")
+		require.NoError(t, pty.Wait())
+		require.NotContains(t, pty.Transcript(), "hunter2")
+	})
+}
+
+func TestPromptForMissingOptions(t *testing.T) {
+	t.Parallel()
+
+	cmd := func() *serpent.Command {
+		var (
+			name, region, token string
+			count               int64
+		)
+		return &serpent.Command{
+			Use: "create",
+			Options: serpent.OptionSet{
+				{
+					Name:        "name",
+					Flag:        "name",
+					Description: "Name of the workspace.",
+					Required:    true,
+					Value:       serpent.StringOf(&name),
+				},
+				{
+					Name:     "region",
+					Flag:     "region",
+					Required: true,
+					Value:    serpent.EnumOf(&region, "us", "eu"),
+				},
+				{
+					Name:        "token",
+					Env:         "TOKEN",
+					Required:    true,
+					Annotations: serpent.Annotations{}.Mark(serpent.AnnotationSecret, "true"),
+					Value:       serpent.StringOf(&token),
+				},
+				{
+					Name:     "count",
+					Flag:     "count",
+					Required: true,
+					Value:    serpent.Int64Of(&count),
+				},
+			},
+			Handler: func(inv *serpent.Invocation) error {
+				_, _ = fmt.Fprintf(inv.Stdout, "%s %s %s %d %s %s\n", name, region, token, count,
+					inv.Options().ByName("name").ValueSource, inv.Options().ByName("token").ValueSource)
+				return nil
+			},
+		}
+	}
+
+	t.Run("Prompt", func(t *testing.T) {
+		t.Parallel()
+
+		inv := cmd().Invoke().WithPromptForMissingOptions().WithInteractiveStdin()
+		io := fakeIO(inv)
+		io.Stdin.WriteString("x\n3\ndev\n2\nabc\n")
+		require.NoError(t, inv.Run())
+		require.Equal(t, "dev eu abc 3 prompt prompt\n", io.Stdout.String())
+		require.Contains(t, io.Stderr.String(), "count int: ")
+		require.Contains(t, io.Stderr.String(), "Name of the workspace.\nname string: ")
+		require.Contains(t, io.Stderr.String(), "region:\n  1) us\n  2) eu\n")
+	})
+
+	t.Run("OnlyMissing", func(t *testing.T) {
+		t.Parallel()
+
+		inv := cmd().Invoke("--name", "dev", "--count", "1", "--region", "us").WithPromptForMissingOptions().WithInteractiveStdin()
+		io := fakeIO(inv)
+		io.Stdin.WriteString("abc\n")
+		require.NoError(t, inv.Run())
+		require.Equal(t, "dev us abc 1 flag prompt\n", io.Stdout.String())
+	})
+
+	t.Run("NotInteractive", func(t *testing.T) {
+		t.Parallel()
+
+		inv := cmd().Invoke("--name", "dev").WithPromptForMissingOptions()
+		fakeIO(inv)
+		require.ErrorContains(t, inv.Run(), "Missing values for the required flags: count, region, token")
+	})
+
+	t.Run("Choices", func(t *testing.T) {
+		t.Parallel()
+
+		var (
+			format string
+			tags   []string
+		)
+		cmd := &serpent.Command{
+			Use: "list",
+			Options: serpent.OptionSet{
+				{
+					Name:     "format",
+					Flag:     "format",
+					Required: true,
+					Value: serpent.EnumOfChoices(&format,
+						serpent.EnumChoice{Value: "table", Description: "Render as a table."},
+						serpent.EnumChoice{Value: "json"},
+						serpent.EnumChoice{Value: "yml", Deprecated: true},
+					),
+				},
+				{
+					Name:     "tag",
+					Flag:     "tag",
+					Required: true,
+					Value:    serpent.EnumArrayOf(&tags, "a", "b"),
+				},
+			},
+			Handler: func(inv *serpent.Invocation) error {
+				_, _ = fmt.Fprintf(inv.Stdout, "%s %s\n", format, strings.Join(tags, ","))
+				return nil
+			},
+		}
+		inv := cmd.Invoke().WithPromptForMissingOptions().WithInteractiveStdin()
+		io := fakeIO(inv)
+		// The values of the failed answer aren't kept.
+		io.Stdin.WriteString("2\na,c\nb\n")
+		require.NoError(t, inv.Run())
+		require.Equal(t, "json b\n", io.Stdout.String())
+		require.Contains(t, io.Stderr.String(), "format:\n  1) table  Render as a table.\n  2) json\nChoose 1-2: ")
+	})
+
+	t.Run("NotEnabled", func(t *testing.T) {
+		t.Parallel()
+
+		inv := cmd().Invoke().WithInteractiveStdin()
+		io := fakeIO(inv)
+		io.Stdin.WriteString("3\ndev\n2\nabc\n")
+		require.ErrorContains(t, inv.Run(), "Missing values for the required flags")
+	})
+}
@@ -0,0 +1,421 @@
+package serpent
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/term"
+	"golang.org/x/xerrors"
+)
+
+// REPLCommand returns a "shell" command that reads subcommands of the root
+// command interactively, without the program name, and runs each line as a
+// fresh invocation of the root with the shell's stdio.
+//
+// The values of the options are reset before each line to what they were
+// when the shell started, so that the options given on one line don't
+// carry over to the next. Flags given before "shell" persist for the whole
+// session. When stdin is a terminal, lines can be edited, recalled from
+// history and completed with TAB. "help [command]" and "exit" are built in, and errors are
+// printed without ending the session.
+func REPLCommand() *Command {
+	return &Command{
+		Use:   "shell",
+		Short: "Start an interactive shell.",
+		Long: "Start an interactive shell that runs commands without the program name. " +
+			"Type \"help\" to list the commands and \"exit\" or Ctrl-D to quit.",
+		Middleware: RequireNArgs(0),
+		Handler: func(inv *Invocation) (err error) {
+			d, end := newDispatcher(inv)
+			defer func() {
+				err = errors.Join(err, end())
+			}()
+			return (&repl{dispatcher: d}).run()
+		},
+	}
+}
+
+// dispatcher runs command lines as invocations of the root command, in
+// the session of the shell or batch command that reads them.
+type dispatcher struct {
+	inv  *Invocation
+	root *Command
+	// globalArgs are the flags of the parent commands given to the shell
+	// or batch command, which are passed to every line.
+	globalArgs []string
+	// options are the option values of the tree, as they were when the
+	// session started.
+	options []*savedOption
+}
+
+// newDispatcher returns a dispatcher for the lines read by inv, and a
+// function that ends its session.
+func newDispatcher(inv *Invocation) (*dispatcher, func() error) {
+	root := inv.Command
+	for root.Parent != nil {
+		root = root.Parent
+	}
+	ctx, end := withSession(inv.Context())
+	return &dispatcher{
+		inv:        inv.WithContext(ctx),
+		root:       root,
+		globalArgs: inv.changedFlagArgs(),
+		options:    saveOptions(root, inv.Command),
+	}, end
+}
+
+// invocation returns an invocation of the root command for args, sharing
+// the dispatcher's stdio, environment and session. The options are reset
+// before it's returned, and its prompts read the lines of stdin.
+func (d *dispatcher) invocation(args []string, stdin *lineReader) *Invocation {
+	for _, o := range d.options {
+		o.restore()
+	}
+	return d.inv.with(func(i *Invocation) {
+		i.Command = d.root
+		i.Args = append(append([]string{}, d.globalArgs...), args...)
+		i.parsedFlags = nil
+		// Running a command closes its Stdin, which must outlive it. A
+		// terminal keeps its file descriptor, for prompts to hide input.
+		i.Stdin = struct{ io.Reader }{stdin.r}
+		if fd, ok := terminalFd(stdin.r); ok {
+			i.Stdin = fdReader{Reader: stdin.r, fd: uintptr(fd)}
+		}
+		i.promptStdin = stdin
+	})
+}
+
+// savedOption is the value of one or more options when a session started.
+type savedOption struct {
+	value pflag.Value
+	opts  []*Option
+	// clone is a copy of the value, if it can be cloned. Otherwise, str
+	// and slice are what it returned from String and GetSlice.
+	clone pflag.Value
+	str   string
+	slice []string
+	// set is true if the value had a source when the session started.
+	// Such values are parsed again by every line, from the same flags,
+	// environment or defaults.
+	set bool
+}
+
+// saveOptions saves the option values of root and its children, except
+// those of skip, the command running the session.
+func saveOptions(root, skip *Command) []*savedOption {
+	var saved []*savedOption
+	byValue := make(map[pflag.Value]*savedOption)
+	root.Walk(func(c *Command) {
+		if c == skip {
+			return
+		}
+		for i := range c.Options {
+			opt := &c.Options[i]
+			if opt.Value == nil {
+				continue
+			}
+			o, ok := byValue[opt.Value]
+			if !ok {
+				o = &savedOption{value: opt.Value, str: opt.Value.String()}
+				o.clone, _ = cloneValue(opt.Value)
+				if sv, ok := opt.Value.(pflag.SliceValue); ok {
+					o.slice = slices.Clone(sv.GetSlice())
+				}
+				byValue[opt.Value] = o
+				saved = append(saved, o)
+			}
+			o.opts = append(o.opts, opt)
+			o.set = o.set || opt.ValueSource != ValueSourceNone
+		}
+	})
+	return saved
+}
+
+// restore resets the value to what it was when it was saved, and clears
+// the sources of its options. Values that were set are parsed again, so
+// slices are emptied rather than restored, for their elements not to be
+// appended twice. Values that can't be cloned are restored from their
+// string, if it changed, which is all that can be done with a pflag.Value.
+func (o *savedOption) restore() {
+	for _, opt := range o.opts {
+		opt.ValueSource = ValueSourceNone
+	}
+	if sv, ok := o.value.(pflag.SliceValue); ok {
+		var vals []string
+		if !o.set {
+			vals = slices.Clone(o.slice)
+		}
+		_ = sv.Replace(vals)
+		return
+	}
+	if o.clone != nil {
+		c, err := cloneValue(o.clone)
+		if err == nil {
+			copyValue(reflect.ValueOf(o.value), reflect.ValueOf(c))
+			return
+		}
+	}
+	if !o.set && o.value.String() != o.str {
+		_ = o.value.Set(o.str)
+	}
+}
+
+// copyValue copies src into dst, which are of the same type. Pointers are
+// followed rather than copied, so that values keep writing to the
+// variables they're bound to.
+func copyValue(dst, src reflect.Value) {
+	switch dst.Kind() {
+	case reflect.Pointer:
+		if dst.IsNil() || src.IsNil() {
+			if dst.CanSet() {
+				dst.Set(src)
+			}
+			return
+		}
+		copyValue(dst.Elem(), src.Elem())
+	case reflect.Interface:
+		if dst.IsNil() || src.IsNil() || dst.Elem().Type() != src.Elem().Type() || dst.Elem().Kind() != reflect.Pointer {
+			if dst.CanSet() {
+				dst.Set(src)
+			}
+			return
+		}
+		copyValue(dst.Elem(), src.Elem())
+	case reflect.Struct:
+		// Unexported fields can only be copied with the whole struct, so
+		// the exported fields that hold pointers are copied into first,
+		// and kept in the copy.
+		tmp := reflect.New(dst.Type()).Elem()
+		tmp.Set(src)
+		for i := 0; i < dst.NumField(); i++ {
+			if !dst.Type().Field(i).IsExported() {
+				continue
+			}
+			switch dst.Field(i).Kind() {
+			case reflect.Pointer, reflect.Interface, reflect.Struct:
+				copyValue(dst.Field(i), tmp.Field(i))
+				tmp.Field(i).Set(dst.Field(i))
+			}
+		}
+		dst.Set(tmp)
+	default:
+		dst.Set(src)
+	}
+}
+
+type repl struct {
+	*dispatcher
+}
+
+func (r *repl) run() error {
+	fd, ok := terminalFd(r.inv.Stdin)
+	if !ok || !r.inv.Terminal().IsTTY() {
+		// Without a terminal, lines are read as is, e.g. from a script.
+		// The reader is shared with the commands so that they can read
+		// the input following their line, and so are the lines read, so
+		// that a line read by a canceled prompt isn't lost.
+		stdin := &lineReader{r: bufio.NewReader(r.inv.Stdin)}
+		return r.loop(func() (string, error) {
+			return stdin.readLine(r.inv.Context(), scanLine)
+		}, stdin)
+	}
+
+	t := term.NewTerminal(struct {
+		io.Reader
+		io.Writer
+	}{r.inv.Stdin, r.inv.Stdout}, r.root.Name()+"> ")
+	t.AutoCompleteCallback = func(line string, pos int, key rune) (string, int, bool) {
+		if key != '\t' {
+			return "", 0, false
+		}
+		return r.complete(t, line, pos)
+	}
+	return r.loop(func() (string, error) {
+		// The terminal is only raw while a line is edited, so that
+		// commands run with the terminal as the user expects it.
+		state, err := term.MakeRaw(fd)
+		if err != nil {
+			return "", xerrors.Errorf("make terminal raw: %w", err)
+		}
+		defer func() {
+			_ = term.Restore(fd, state)
+		}()
+		_ = t.SetSize(r.inv.Terminal().Width(), r.inv.Terminal().Height())
+		line, err := t.ReadLine()
+		if errors.Is(err, term.ErrPasteIndicator) {
+			err = nil
+		}
+		return line, err
+	}, &lineReader{r: r.inv.Stdin})
+}
+
+// loop reads lines with readLine and runs them until exit or the end of
+// the input.
+func (r *repl) loop(readLine func() (string, error), stdin *lineReader) error {
+	ctx := r.inv.Context()
+	for ctx.Err() == nil {
+		line, err := readLine()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return xerrors.Errorf("read line: %w", err)
+		}
+
+		args, err := splitArgs(line, false)
+		if err != nil {
+			r.printError(err)
+			continue
+		}
+		if len(args) == 0 {
+			continue
+		}
+		switch args[0] {
+		case "exit", "quit":
+			return nil
+		case "help":
+			args = append(args[1:], "--help")
+		}
+
+		err = r.invocation(args, stdin).Run()
+		if err != nil {
+			r.printError(err)
+		}
+	}
+	return ctx.Err()
+}
+
+func (r *repl) printError(err error) {
+	var unknown *UnknownSubcommandError
+	if errors.As(err, &unknown) {
+		// The help handler already reported it.
+		return
+	}
+	_, _ = fmt.Fprintf(r.inv.Stderr, "%s %v\n", prettyHeader(r.inv.stderrTerminal().ColorProfile(), "error"), err)
+}
+
+// complete completes the word before pos in line. It inserts the completion
+// if there's only one, or the common prefix of all of them. Otherwise, the
+// completions are listed above the prompt.
+func (r *repl) complete(t *term.Terminal, line string, pos int) (string, int, bool) {
+	words, err := splitArgs(line[:pos], true)
+	if err != nil || len(words) == 0 {
+		return "", 0, false
+	}
+	cur := words[len(words)-1]
+
+	var candidates []string
+	for _, c := range r.completions(line[:pos]) {
+		if strings.HasPrefix(c, cur) {
+			candidates = append(candidates, c)
+		}
+	}
+	if len(candidates) == 0 {
+		return "", 0, false
+	}
+
+	completion := candidates[0]
+	if len(candidates) == 1 {
+		if !strings.HasSuffix(completion, "=") && !strings.HasSuffix(completion, string(os.PathSeparator)) {
+			completion += " "
+		}
+	} else {
+		for _, c := range candidates[1:] {
+			completion = commonPrefix(completion, c)
+		}
+		if completion == cur {
+			_, _ = fmt.Fprintln(t, strings.Join(candidates, "  "))
+			return "", 0, false
+		}
+	}
+
+	start := strings.LastIndexAny(line[:pos], " \t") + 1
+	return line[:start] + completion + line[pos:], start + len(completion), true
+}
+
+// completions runs the root command in completion mode for line.
+func (r *repl) completions(line string) []string {
+	var out bytes.Buffer
+	inv := r.invocation(nil, &lineReader{r: strings.NewReader("")})
+	inv.Stdout = &out
+	inv.Stderr = io.Discard
+	inv.Environ = append(Environ{}, inv.Environ...)
+	inv.Environ.Set(CompletionModeEnv, "1")
+	inv.Environ.Set(CompletionLineEnv, strings.Join(append([]string{r.root.Name()}, quoteArgs(r.globalArgs)...), " ")+" "+line)
+	if err := inv.Run(); err != nil {
+		return nil
+	}
+	return strings.FieldsFunc(out.String(), func(r rune) bool { return r == '\n' })
+}
+
+// changedFlagArgs returns the flags of the command's parents that were
+// given to the invocation, as arguments that give them again.
+func (inv *Invocation) changedFlagArgs() []string {
+	var args []string
+	if inv.parsedFlags == nil {
+		return nil
+	}
+	inv.parsedFlags.Visit(func(f *pflag.Flag) {
+		for _, opt := range inv.Command.Options {
+			if opt.Flag == f.Name {
+				return
+			}
+		}
+		if sv, ok := f.Value.(pflag.SliceValue); ok {
+			for _, v := range sv.GetSlice() {
+				args = append(args, "--"+f.Name+"="+v)
+			}
+			return
+		}
+		args = append(args, "--"+f.Name+"="+f.Value.String())
+	})
+	return args
+}
+
+// terminalFd returns the file descriptor of r if it's a terminal.
+func terminalFd(r io.Reader) (int, bool) {
+	f, ok := r.(interface{ Fd() uintptr })
+	if !ok || !term.IsTerminal(int(f.Fd())) {
+		return 0, false
+	}
+	return int(f.Fd()), true
+}
+
+// fdReader is a reader of the terminal with file descriptor fd.
+type fdReader struct {
+	io.Reader
+	fd uintptr
+}
+
+func (r fdReader) Fd() uintptr {
+	return r.fd
+}
+
+// quoteArgs quotes args for splitArgs.
+func quoteArgs(args []string) []string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+	}
+	return quoted
+}
+
+func commonPrefix(a, b string) string {
+	for i := 0; i < len(a) && i < len(b); i++ {
+		if a[i] != b[i] {
+			return a[:i]
+		}
+	}
+	if len(a) < len(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,194 @@
+package serpent_test
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/xerrors"
+
+	serpent "github.com/coder/serpent"
+	"github.com/coder/serpent/serpenttest"
+)
+
+func replCommand() *serpent.Command {
+	var (
+		upper    bool
+		name     string
+		greeting string
+	)
+	return &serpent.Command{
+		Use: "prog",
+		Options: serpent.OptionSet{{
+			Name:        "upper",
+			Flag:        "upper",
+			Description: "Print in uppercase.",
+			Value:       serpent.BoolOf(&upper),
+		}},
+		Children: []*serpent.Command{
+			{
+				Use:   "echo",
+				Short: "Print the arguments.",
+				Handler: func(inv *serpent.Invocation) error {
+					s := strings.Join(inv.Args, " ")
+					if upper {
+						s = strings.ToUpper(s)
+					}
+					_, _ = fmt.Fprintf(inv.Stdout, "said: %s\n", s)
+					return nil
+				},
+			},
+			{
+				Use: "greet",
+				Options: serpent.OptionSet{{
+					Name:     "name",
+					Flag:     "name",
+					Required: true,
+					Value:    serpent.StringOf(&name),
+				}, {
+					Name:    "greeting",
+					Flag:    "greeting",
+					Default: "hello",
+					Value:   serpent.StringOf(&greeting),
+				}},
+				Handler: func(inv *serpent.Invocation) error {
+					_, _ = fmt.Fprintf(inv.Stdout, "%s %s\n", greeting, name)
+					return nil
+				},
+			},
+			{
+				Use: "fail",
+				Handler: func(inv *serpent.Invocation) error {
+					return xerrors.New("oops")
+				},
+			},
+			serpent.REPLCommand(),
+		},
+	}
+}
+
+func TestREPL(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Script", func(t *testing.T) {
+		t.Parallel()
+
+		res := serpenttest.Run(t, replCommand(), serpenttest.RunOptions{
+			Args: []string{"--upper", "shell"},
+			Stdin: strings.Join([]string{
+				"echo hi",
+				"",
+				"fail",
+				"echo 'unterminated",
+				"help echo",
+				"exit",
+				"echo after",
+			}, "\n"),
+		})
+		require.NoError(t, res.Err)
+		require.Contains(t, res.Stdout, "said: HI\n")
+		require.Contains(t, res.Stdout, "Print the arguments.")
+		require.NotContains(t, res.Stdout, "AFTER")
+		require.Contains(t, res.Stderr, "oops")
+		require.Contains(t, res.Stderr, "unterminated ' quote")
+	})
+
+	t.Run("EOF", func(t *testing.T) {
+		t.Parallel()
+
+		res := serpenttest.Run(t, replCommand(), serpenttest.RunOptions{
+			Args:  []string{"shell"},
+			Stdin: "echo a\necho b",
+		})
+		require.NoError(t, res.Err)
+		require.Equal(t, "said: a\nsaid: b\n", res.Stdout)
+	})
+
+	t.Run("OptionsPerLine", func(t *testing.T) {
+		t.Parallel()
+
+		// Options given on a line are seen by the handlers through their
+		// bound variables, and don't carry over to the next line.
+		res := serpenttest.Run(t, replCommand(), serpenttest.RunOptions{
+			Args:  []string{"shell"},
+			Stdin: "echo --upper a\necho b\ngreet --name bob --greeting hi\ngreet --name al\ngreet\n",
+		})
+		require.NoError(t, res.Err)
+		require.Equal(t, "said: A\nsaid: b\nhi bob\nhello al\n", res.Stdout)
+		require.Contains(t, res.Stderr, "Missing values for the required flags: name")
+	})
+
+	t.Run("CustomValue", func(t *testing.T) {
+		t.Parallel()
+
+		// Values that can't be cloned are reset too.
+		color := &plainValue{s: "red"}
+		cmd := &serpent.Command{
+			Use: "prog",
+			Children: []*serpent.Command{
+				{
+					Use: "paint",
+					Options: serpent.OptionSet{{
+						Name:  "color",
+						Flag:  "color",
+						Value: color,
+					}},
+					Handler: func(inv *serpent.Invocation) error {
+						_, _ = fmt.Fprintf(inv.Stdout, "painted %s\n", color)
+						return nil
+					},
+				},
+				serpent.REPLCommand(),
+			},
+		}
+		res := serpenttest.Run(t, cmd, serpenttest.RunOptions{
+			Args:  []string{"shell"},
+			Stdin: "paint --color blue\npaint\n",
+		})
+		require.NoError(t, res.Err)
+		require.Equal(t, "painted blue\npainted red\n", res.Stdout)
+	})
+
+	t.Run("Args", func(t *testing.T) {
+		t.Parallel()
+
+		res := serpenttest.Run(t, replCommand(), serpenttest.RunOptions{
+			Args: []string{"shell", "echo"},
+		})
+		require.Error(t, res.Err)
+	})
+
+	t.Run("Terminal", func(t *testing.T) {
+		t.Parallel()
+
+		pty := serpenttest.StartPTY(t, replCommand(), serpenttest.PTYOptions{
+			Args: []string{"shell"},
+		})
+		pty.ExpectString("prog> ")
+
+		// A single completion is inserted, followed by a space.
+		pty.Write("ec\t")
+		pty.ExpectString("echo ")
+		pty.WriteLine("hi")
+		pty.ExpectString("said: hi")
+
+		// Flags are completed too.
+		pty.ExpectString("prog> ")
+		pty.Write("echo --up\t")
+		pty.ExpectString("--upper ")
+		pty.WriteLine("x")
+		pty.ExpectString("said: X")
+
+		// The previous line is recalled from history.
+		pty.ExpectString("prog> ")
+		pty.Write("\x1b[A")
+		pty.ExpectString("echo --upper x")
+		pty.WriteLine("")
+		pty.ExpectString("said: X")
+
+		pty.ExpectString("prog> ")
+		pty.WriteLine("exit")
+		require.NoError(t, pty.Wait())
+	})
+}
@@ -0,0 +1,479 @@
+package serpent
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/xerrors"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	_ pflag.Value = (*SemVer)(nil)
+	_ pflag.Value = (*SemVerConstraint)(nil)
+)
+
+// SemVer is a semantic version of form "1.2.3", optionally prefixed with
+// "v" and followed by "-prerelease" and "+build" parts.
+type SemVer struct {
+	Major      uint64
+	Minor      uint64
+	Patch      uint64
+	Prerelease string
+	Build      string
+}
+
+// ParseSemVer parses a full semantic version such as "v1.2.3-rc.1".
+func ParseSemVer(s string) (SemVer, error) {
+	v, parts, err := parsePartialSemVer(s)
+	if err != nil {
+		return SemVer{}, err
+	}
+	if parts != 3 {
+		return SemVer{}, xerrors.Errorf("invalid version %q: expected major.minor.patch", s)
+	}
+	return v, nil
+}
+
+// parsePartialSemVer parses versions that may omit the minor and patch
+// parts, or use "x" or "*" wildcards in their place, as is common in
+// constraints. It returns how many parts were given.
+func parsePartialSemVer(s string) (v SemVer, parts int, err error) {
+	raw := s
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if s == "" {
+		return SemVer{}, 0, xerrors.Errorf("invalid version %q: empty", raw)
+	}
+
+	if i := strings.IndexByte(s, '+'); i >= 0 {
+		v.Build = s[i+1:]
+		s = s[:i]
+		if err := validSemVerIdentifiers(v.Build, false); err != nil {
+			return SemVer{}, 0, xerrors.Errorf("invalid version %q: build: %w", raw, err)
+		}
+	}
+	if i := strings.IndexByte(s, '-'); i >= 0 {
+		v.Prerelease = s[i+1:]
+		s = s[:i]
+		if err := validSemVerIdentifiers(v.Prerelease, true); err != nil {
+			return SemVer{}, 0, xerrors.Errorf("invalid version %q: prerelease: %w", raw, err)
+		}
+	}
+
+	nums := strings.Split(s, ".")
+	if len(nums) > 3 {
+		return SemVer{}, 0, xerrors.Errorf("invalid version %q: too many parts", raw)
+	}
+	dst := []*uint64{&v.Major, &v.Minor, &v.Patch}
+	for i, n := range nums {
+		if n == "x" || n == "X" || n == "*" {
+			if v.Prerelease != "" || v.Build != "" {
+				return SemVer{}, 0, xerrors.Errorf("invalid version %q: wildcards cannot have a prerelease or build", raw)
+			}
+			// Only more wildcards may follow, as in "1.x.x".
+			for _, rest := range nums[i+1:] {
+				if rest != "x" && rest != "X" && rest != "*" {
+					return SemVer{}, 0, xerrors.Errorf("invalid version %q: %q follows a wildcard", raw, rest)
+				}
+			}
+			return v, i, nil
+		}
+		if n == "" {
+			return SemVer{}, 0, xerrors.Errorf("invalid version %q: empty part", raw)
+		}
+		if len(n) > 1 && n[0] == '0' {
+			return SemVer{}, 0, xerrors.Errorf("invalid version %q: leading zero in %q", raw, n)
+		}
+		num, err := strconv.ParseUint(n, 10, 64)
+		if err != nil {
+			return SemVer{}, 0, xerrors.Errorf("invalid version %q: %q is not a number", raw, n)
+		}
+		*dst[i] = num
+	}
+	if len(nums) < 3 && (v.Prerelease != "" || v.Build != "") {
+		return SemVer{}, 0, xerrors.Errorf("invalid version %q: prerelease and build require major.minor.patch", raw)
+	}
+	return v, len(nums), nil
+}
+
+func validSemVerIdentifiers(s string, prerelease bool) error {
+	for _, id := range strings.Split(s, ".") {
+		if id == "" {
+			return xerrors.Errorf("empty identifier")
+		}
+		numeric := true
+		for _, r := range id {
+			switch {
+			case r >= '0' && r <= '9':
+			case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r == '-':
+				numeric = false
+			default:
+				return xerrors.Errorf("invalid character %q in %q", r, id)
+			}
+		}
+		if prerelease && numeric && len(id) > 1 && id[0] == '0' {
+			return xerrors.Errorf("leading zero in %q", id)
+		}
+	}
+	return nil
+}
+
+func (v SemVer) String() string {
+	s := fmt.Sprintf("%d.%d.%d", v.Major, v.Minor, v.Patch)
+	if v.Prerelease != "" {
+		s += "-" + v.Prerelease
+	}
+	if v.Build != "" {
+		s += "+" + v.Build
+	}
+	return s
+}
+
+// Compare returns -1, 0 or 1 if v is less than, equal to or greater than
+// o, following semver precedence. Build metadata is ignored.
+func (v SemVer) Compare(o SemVer) int {
+	for _, d := range [][2]uint64{
+		{v.Major, o.Major},
+		{v.Minor, o.Minor},
+		{v.Patch, o.Patch},
+	} {
+		if d[0] != d[1] {
+			return ascendingSortFn(d[0], d[1])
+		}
+	}
+	return comparePrerelease(v.Prerelease, o.Prerelease)
+}
+
+func comparePrerelease(a, b string) int {
+	// A version without a prerelease has higher precedence.
+	switch {
+	case a == b:
+		return 0
+	case a == "":
+		return 1
+	case b == "":
+		return -1
+	}
+	as, bs := strings.Split(a, "."), strings.Split(b, ".")
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		an, aErr := strconv.ParseUint(as[i], 10, 64)
+		bn, bErr := strconv.ParseUint(bs[i], 10, 64)
+		switch {
+		case aErr == nil && bErr == nil:
+			if an != bn {
+				return ascendingSortFn(an, bn)
+			}
+		case aErr == nil:
+			// Numeric identifiers have lower precedence.
+			return -1
+		case bErr == nil:
+			return 1
+		default:
+			if as[i] != bs[i] {
+				return ascendingSortFn(as[i], bs[i])
+			}
+		}
+	}
+	return ascendingSortFn(len(as), len(bs))
+}
+
+func (v SemVer) Equal(o SemVer) bool       { return v.Compare(o) == 0 }
+func (v SemVer) LessThan(o SemVer) bool    { return v.Compare(o) < 0 }
+func (v SemVer) GreaterThan(o SemVer) bool { return v.Compare(o) > 0 }
+
+// IsZero returns true if the version is unset.
+func (v SemVer) IsZero() bool {
+	return v == SemVer{}
+}
+
+func (v *SemVer) Set(s string) error {
+	if s == "" {
+		*v = SemVer{}
+		return nil
+	}
+	vv, err := ParseSemVer(s)
+	if err != nil {
+		return err
+	}
+	*v = vv
+	return nil
+}
+
+func (*SemVer) Type() string {
+	return "semver"
+}
+
+func (v *SemVer) CloneValue() (pflag.Value, error) {
+	return clonePtr(v), nil
+}
+
+func (v *SemVer) MarshalYAML() (interface{}, error) {
+	return yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Value: v.String(),
+	}, nil
+}
+
+func (v *SemVer) UnmarshalYAML(n *yaml.Node) error {
+	return v.Set(n.Value)
+}
+
+func (v *SemVer) MarshalJSON() ([]byte, error) {
+	return json.Marshal(v.String())
+}
+
+func (v *SemVer) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	return v.Set(s)
+}
+
+// semverComparator is a single primitive comparison against a full version.
+type semverComparator struct {
+	op string
+	v  SemVer
+}
+
+func (c semverComparator) check(v SemVer) bool {
+	cmp := v.Compare(c.v)
+	switch c.op {
+	case "=":
+		return cmp == 0
+	case "!=":
+		return cmp != 0
+	case ">":
+		return cmp > 0
+	case ">=":
+		return cmp >= 0
+	case "<":
+		return cmp < 0
+	case "<=":
+		return cmp <= 0
+	}
+	return false
+}
+
+func (c semverComparator) String() string {
+	return c.op + c.v.String()
+}
+
+// SemVerConstraint is a set of version requirements such as ">=1.2 <2",
+// "~1.4" or "^2.0.0 || ^3.0.0". Comparators separated by spaces or commas
+// must all match, and "||" separates alternatives.
+//
+// Supported operators are "=", "!=", ">", ">=", "<", "<=", "~" (patch
+// updates) and "^" (updates that don't change the left-most non-zero
+// part). Versions may be partial, e.g. "1.2" or "1.x".
+type SemVerConstraint struct {
+	raw  string
+	sets [][]semverComparator
+}
+
+// ParseSemVerConstraint parses a constraint such as ">=1.2 <2".
+func ParseSemVerConstraint(s string) (SemVerConstraint, error) {
+	c := SemVerConstraint{raw: strings.TrimSpace(s)}
+	if c.raw == "" {
+		return c, nil
+	}
+	for _, alt := range strings.Split(c.raw, "||") {
+		tokens := strings.Fields(strings.ReplaceAll(alt, ",", " "))
+		if len(tokens) == 0 {
+			return SemVerConstraint{}, xerrors.Errorf("invalid constraint %q: empty alternative", s)
+		}
+		var set []semverComparator
+		for i := 0; i < len(tokens); i++ {
+			tok := tokens[i]
+			// Allow a space between the operator and the version.
+			if strings.Trim(tok, "=!<>~^") == "" && i+1 < len(tokens) {
+				i++
+				tok += tokens[i]
+			}
+			cs, err := parseSemVerComparator(tok)
+			if err != nil {
+				return SemVerConstraint{}, xerrors.Errorf("invalid constraint %q: %w", s, err)
+			}
+			set = append(set, cs...)
+		}
+		c.sets = append(c.sets, set)
+	}
+	return c, nil
+}
+
+// parseSemVerComparator expands a single, possibly partial, comparator
+// into primitive comparators against full versions.
+func parseSemVerComparator(s string) ([]semverComparator, error) {
+	op := s[:len(s)-len(strings.TrimLeft(s, "=!<>~^"))]
+	switch op {
+	case "", "=", "==", "!=", ">", ">=", "<", "<=", "~", "^":
+	default:
+		return nil, xerrors.Errorf("unknown operator %q", op)
+	}
+	v, parts, err := parsePartialSemVer(s[len(op):])
+	if err != nil {
+		return nil, err
+	}
+
+	// next returns the smallest version that is above every version
+	// matching the first n parts of v.
+	next := func(n int) SemVer {
+		switch n {
+		case 0:
+			return SemVer{}
+		case 1:
+			return SemVer{Major: v.Major + 1, Prerelease: "0"}
+		case 2:
+			return SemVer{Major: v.Major, Minor: v.Minor + 1, Prerelease: "0"}
+		default:
+			return SemVer{Major: v.Major, Minor: v.Minor, Patch: v.Patch + 1, Prerelease: "0"}
+		}
+	}
+	lower := SemVer{Major: v.Major, Minor: v.Minor, Patch: v.Patch, Prerelease: v.Prerelease}
+	matchAll := []semverComparator{{op: ">=", v: SemVer{Prerelease: "0"}}}
+
+	switch op {
+	case "", "=", "==":
+		if parts == 3 {
+			return []semverComparator{{op: "=", v: lower}}, nil
+		}
+		if parts == 0 {
+			return matchAll, nil
+		}
+		return []semverComparator{{op: ">=", v: lower}, {op: "<", v: next(parts)}}, nil
+	case "!=":
+		if parts != 3 {
+			return nil, xerrors.Errorf("%q requires a full version", s)
+		}
+		return []semverComparator{{op: "!=", v: lower}}, nil
+	case ">":
+		if parts == 0 {
+			return []semverComparator{{op: "<", v: SemVer{Prerelease: "0"}}}, nil
+		}
+		if parts == 3 {
+			return []semverComparator{{op: ">", v: lower}}, nil
+		}
+		return []semverComparator{{op: ">=", v: next(parts)}}, nil
+	case ">=":
+		return []semverComparator{{op: ">=", v: lower}}, nil
+	case "<":
+		if parts == 3 && v.Prerelease != "" {
+			return []semverComparator{{op: "<", v: lower}}, nil
+		}
+		lower.Prerelease = "0"
+		return []semverComparator{{op: "<", v: lower}}, nil
+	case "<=":
+		if parts == 3 {
+			return []semverComparator{{op: "<=", v: lower}}, nil
+		}
+		if parts == 0 {
+			return matchAll, nil
+		}
+		return []semverComparator{{op: "<", v: next(parts)}}, nil
+	case "~":
+		if parts == 0 {
+			return matchAll, nil
+		}
+		upper := next(2)
+		if parts == 1 {
+			upper = next(1)
+		}
+		return []semverComparator{{op: ">=", v: lower}, {op: "<", v: upper}}, nil
+	case "^":
+		if parts == 0 {
+			return matchAll, nil
+		}
+		var upper SemVer
+		switch {
+		case v.Major > 0 || parts == 1:
+			upper = next(1)
+		case v.Minor > 0 || parts == 2:
+			upper = next(2)
+		default:
+			upper = next(3)
+		}
+		return []semverComparator{{op: ">=", v: lower}, {op: "<", v: upper}}, nil
+	}
+	return nil, xerrors.Errorf("unknown operator %q", op)
+}
+
+// Check returns true if v satisfies the constraint. An empty constraint
+// is satisfied by every version.
+func (c SemVerConstraint) Check(v SemVer) bool {
+	if len(c.sets) == 0 {
+		return true
+	}
+setLoop:
+	for _, set := range c.sets {
+		for _, cmp := range set {
+			if !cmp.check(v) {
+				continue setLoop
+			}
+		}
+		return true
+	}
+	return false
+}
+
+// Validate returns a descriptive error if v does not satisfy the
+// constraint.
+func (c SemVerConstraint) Validate(v SemVer) error {
+	if c.Check(v) {
+		return nil
+	}
+	return xerrors.Errorf("version %s does not satisfy constraint %q", v, c.raw)
+}
+
+// IsZero returns true if the constraint is unset.
+func (c SemVerConstraint) IsZero() bool {
+	return c.raw == ""
+}
+
+func (c SemVerConstraint) String() string {
+	return c.raw
+}
+
+func (c *SemVerConstraint) Set(s string) error {
+	cc, err := ParseSemVerConstraint(s)
+	if err != nil {
+		return err
+	}
+	*c = cc
+	return nil
+}
+
+func (*SemVerConstraint) Type() string {
+	return "semver-constraint"
+}
+
+func (c *SemVerConstraint) CloneValue() (pflag.Value, error) {
+	return clonePtr(c), nil
+}
+
+func (c *SemVerConstraint) MarshalYAML() (interface{}, error) {
+	return yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Value: c.String(),
+	}, nil
+}
+
+func (c *SemVerConstraint) UnmarshalYAML(n *yaml.Node) error {
+	return c.Set(n.Value)
+}
+
+func (c *SemVerConstraint) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.String())
+}
+
+func (c *SemVerConstraint) UnmarshalJSON(b []byte) error {
+	var s string
+	err := json.Unmarshal(b, &s)
+	if err != nil {
+		return err
+	}
+	return c.Set(s)
+}
@@ -0,0 +1,205 @@
+package serpent_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	serpent "github.com/coder/serpent"
+)
+
+func TestSemVer(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Parse", func(t *testing.T) {
+		t.Parallel()
+		v, err := serpent.ParseSemVer("v1.2.3-rc.1+build.5")
+		require.NoError(t, err)
+		require.Equal(t, serpent.SemVer{Major: 1, Minor: 2, Patch: 3, Prerelease: "rc.1", Build: "build.5"}, v)
+		require.Equal(t, "1.2.3-rc.1+build.5", v.String())
+
+		for _, bad := range []string{"", "1.2", "1.2.3.4", "01.2.3", "1.a.3", "1.2.3-", "1.2.3-rc..1", "1.x.3"} {
+			_, err := serpent.ParseSemVer(bad)
+			require.Error(t, err, bad)
+			require.Contains(t, err.Error(), "invalid version", bad)
+		}
+	})
+
+	t.Run("Compare", func(t *testing.T) {
+		t.Parallel()
+		ordered := []string{
+			"1.0.0-alpha",
+			"1.0.0-alpha.1",
+			"1.0.0-alpha.beta",
+			"1.0.0-beta",
+			"1.0.0-beta.2",
+			"1.0.0-beta.11",
+			"1.0.0-rc.1",
+			"1.0.0",
+			"1.0.1",
+			"1.10.0",
+			"2.0.0",
+		}
+		for i := 1; i < len(ordered); i++ {
+			a, err := serpent.ParseSemVer(ordered[i-1])
+			require.NoError(t, err)
+			b, err := serpent.ParseSemVer(ordered[i])
+			require.NoError(t, err)
+			require.True(t, a.LessThan(b), "%s < %s", a, b)
+			require.True(t, b.GreaterThan(a), "%s > %s", b, a)
+		}
+
+		a, _ := serpent.ParseSemVer("1.0.0+a")
+		b, _ := serpent.ParseSemVer("1.0.0+b")
+		require.True(t, a.Equal(b))
+	})
+
+	t.Run("Marshal", func(t *testing.T) {
+		t.Parallel()
+		var v serpent.SemVer
+		require.NoError(t, v.Set("v2.1.0"))
+		require.Equal(t, "semver", v.Type())
+
+		byt, err := json.Marshal(&v)
+		require.NoError(t, err)
+		require.Equal(t, `"2.1.0"`, string(byt))
+
+		var v2 serpent.SemVer
+		require.NoError(t, json.Unmarshal(byt, &v2))
+		require.Equal(t, v, v2)
+
+		byt, err = yaml.Marshal(&v)
+		require.NoError(t, err)
+		require.Equal(t, "2.1.0\n", string(byt))
+
+		var v3 serpent.SemVer
+		require.NoError(t, yaml.Unmarshal(byt, &v3))
+		require.Equal(t, v, v3)
+	})
+}
+
+func TestSemVerConstraint(t *testing.T) {
+	t.Parallel()
+
+	cases := []struct {
+		constraint string
+		match      []string
+		noMatch    []string
+	}{
+		{
+			constraint: ">=1.2 <2",
+			match:      []string{"1.2.0", "1.9.9"},
+			noMatch:    []string{"1.1.9", "2.0.0", "2.0.0-rc.1"},
+		},
+		{
+			constraint: ">= 1.2.3, < 1.3",
+			match:      []string{"1.2.3", "1.2.10"},
+			noMatch:    []string{"1.2.2", "1.3.0"},
+		},
+		{
+			constraint: "~1.4",
+			match:      []string{"1.4.0", "1.4.7"},
+			noMatch:    []string{"1.3.9", "1.5.0"},
+		},
+		{
+			constraint: "^1.2.3",
+			match:      []string{"1.2.3", "1.9.0"},
+			noMatch:    []string{"1.2.2", "2.0.0"},
+		},
+		{
+			constraint: "^0.2.3",
+			match:      []string{"0.2.3", "0.2.9"},
+			noMatch:    []string{"0.3.0"},
+		},
+		{
+			constraint: "1.x",
+			match:      []string{"1.0.0", "1.99.0"},
+			noMatch:    []string{"0.9.0", "2.0.0"},
+		},
+		{
+			constraint: "1.x.x",
+			match:      []string{"1.0.0", "1.99.0"},
+			noMatch:    []string{"2.0.0"},
+		},
+		{
+			constraint: ">1.2",
+			match:      []string{"1.3.0"},
+			noMatch:    []string{"1.2.9"},
+		},
+		{
+			constraint: "<=1.2",
+			match:      []string{"1.2.9"},
+			noMatch:    []string{"1.3.0"},
+		},
+		{
+			constraint: "^1 || ^3",
+			match:      []string{"1.5.0", "3.0.0"},
+			noMatch:    []string{"2.0.0"},
+		},
+		{
+			constraint: "!=1.2.3",
+			match:      []string{"1.2.4"},
+			noMatch:    []string{"1.2.3"},
+		},
+		{
+			constraint: "",
+			match:      []string{"0.0.1", "9.9.9"},
+		},
+	}
+
+	for _, tc := range cases {
+		tc := tc
+		t.Run(tc.constraint, func(t *testing.T) {
+			t.Parallel()
+			var c serpent.SemVerConstraint
+			require.NoError(t, c.Set(tc.constraint))
+			require.Equal(t, tc.constraint, c.String())
+			for _, s := range tc.match {
+				v, err := serpent.ParseSemVer(s)
+				require.NoError(t, err)
+				require.True(t, c.Check(v), "%s should satisfy %q", s, tc.constraint)
+				require.NoError(t, c.Validate(v))
+			}
+			for _, s := range tc.noMatch {
+				v, err := serpent.ParseSemVer(s)
+				require.NoError(t, err)
+				require.False(t, c.Check(v), "%s should not satisfy %q", s, tc.constraint)
+				require.ErrorContains(t, c.Validate(v), "does not satisfy constraint")
+			}
+		})
+	}
+
+	t.Run("Invalid", func(t *testing.T) {
+		t.Parallel()
+		for _, bad := range []string{">=1.2 ||", "=>1.2", ">=1.2.x.4", "!=1.2", "1.x.3", "~1.*.0"} {
+			_, err := serpent.ParseSemVerConstraint(bad)
+			require.Error(t, err, bad)
+			require.Contains(t, err.Error(), "invalid constraint", bad)
+		}
+	})
+
+	t.Run("Option", func(t *testing.T) {
+		t.Parallel()
+		var (
+			minVersion serpent.SemVer
+			constraint serpent.SemVerConstraint
+		)
+		cmd := &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{
+				{Name: "min-agent-version", Flag: "min-agent-version", Value: &minVersion},
+				{Name: "version-constraint", Env: "VERSION_CONSTRAINT", Value: &constraint, Default: ">=1"},
+			},
+			Handler: func(i *serpent.Invocation) error { return nil },
+		}
+		inv := cmd.Invoke("--min-agent-version", "v2.3.0")
+		inv.Environ.Set("VERSION_CONSTRAINT", "~2.3")
+		require.NoError(t, inv.Run())
+		require.True(t, constraint.Check(minVersion))
+
+		err := cmd.Invoke("--min-agent-version", "latest").Run()
+		require.ErrorContains(t, err, `invalid version "latest"`)
+	})
+}
@@ -0,0 +1,73 @@
+package serpenttest
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// UpdateFlag is the name of the test flag that makes Golden and RunScript
+// rewrite expected output instead of comparing it. This package doesn't
+// register it, so that it can't clash with a package's own -update flag.
+// A package that has none binds Update to it:
+//
+//	func init() {
+//		flag.BoolVar(&serpenttest.Update, serpenttest.UpdateFlag, false, "update golden files")
+//	}
+const UpdateFlag = "update"
+
+// Update makes Golden and RunScript rewrite expected output instead of
+// comparing it. They also do if a boolean -update flag is registered and
+// set.
+var Update bool
+
+// updating returns whether expected output should be rewritten.
+func updating() bool {
+	if Update {
+		return true
+	}
+	f := flag.Lookup(UpdateFlag)
+	if f == nil {
+		return false
+	}
+	getter, ok := f.Value.(flag.Getter)
+	if !ok {
+		return f.Value.String() == "true"
+	}
+	update, _ := getter.Get().(bool)
+	return update
+}
+
+// GoldenPath returns the path of the golden file with the given name,
+// relative to the package directory.
+func GoldenPath(name string) string {
+	return filepath.Join("testdata", name+".golden")
+}
+
+// Golden compares got with the golden file testdata/<name>.golden. If the
+// tests were run with -update, the golden file is written instead.
+func Golden(t testing.TB, name string, got []byte) {
+	t.Helper()
+
+	path := GoldenPath(name)
+	if updating() {
+		err := os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			t.Fatalf("create golden directory: %v", err)
+		}
+		err = os.WriteFile(path, got, 0o644)
+		if err != nil {
+			t.Fatalf("write golden file: %v", err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read golden file, run with -%s to create it: %v", UpdateFlag, err)
+	}
+	if string(want) != string(got) {
+		t.Errorf("output does not match %s, run with -%s to update it\ngot:\n%s\nwant:\n%s", path, UpdateFlag, got, want)
+	}
+}
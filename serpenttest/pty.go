@@ -0,0 +1,218 @@
+package serpenttest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/serpent"
+)
+
+// errPTYUnsupported is returned by openPTY on platforms without
+// pseudo-terminal support.
+var errPTYUnsupported = errors.New("pseudo-terminals are not supported on this platform")
+
+// PTYOptions configures StartPTY.
+type PTYOptions struct {
+	// Args are the arguments passed to the command, without the program
+	// name.
+	Args []string
+	// Env is the command's environment, as "KEY=VALUE" pairs.
+	Env []string
+	// Rows and Cols are the initial size of the terminal. If zero, the
+	// terminal is 24 rows by 80 columns.
+	Rows, Cols uint16
+	// Timeout is how long ExpectString and Wait wait. If zero,
+	// DefaultTimeout is used.
+	Timeout time.Duration
+}
+
+// PTY is a command running attached to a pseudo-terminal. Its stdin,
+// stdout and stderr are the terminal, so it detects a TTY as it would when
+// run by a user. The output is collected into a transcript, which is
+// logged if the test fails.
+type PTY struct {
+	t       testing.TB
+	master  *os.File
+	tty     *os.File
+	timeout time.Duration
+	cancel  context.CancelFunc
+
+	mu         sync.Mutex
+	transcript strings.Builder
+	// read is the offset in the transcript up to which ExpectString has
+	// consumed output.
+	read    int
+	changed chan struct{}
+	closed  bool
+
+	done chan struct{}
+	err  error
+}
+
+// StartPTY runs cmd attached to a new pseudo-terminal. The test is
+// skipped on platforms other than Linux. The command is canceled and the
+// terminal closed when the test ends.
+func StartPTY(t testing.TB, cmd *serpent.Command, opts PTYOptions) *PTY {
+	t.Helper()
+
+	master, tty, err := openPTY()
+	if errors.Is(err, errPTYUnsupported) {
+		t.Skip(err.Error())
+	}
+	if err != nil {
+		t.Fatalf("open pty: %v", err)
+	}
+
+	rows, cols := opts.Rows, opts.Cols
+	if rows == 0 {
+		rows = 24
+	}
+	if cols == 0 {
+		cols = 80
+	}
+	err = setPTYSize(tty, rows, cols)
+	if err != nil {
+		t.Fatalf("set pty size: %v", err)
+	}
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &PTY{
+		t:       t,
+		master:  master,
+		tty:     tty,
+		timeout: timeout,
+		cancel:  cancel,
+		changed: make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	t.Cleanup(func() {
+		cancel()
+		_ = tty.Close()
+		_ = master.Close()
+		if t.Failed() {
+			t.Logf("pty transcript:\n%s", p.Transcript())
+		}
+	})
+
+	go p.readLoop()
+
+	inv := cmd.Invoke(opts.Args...).
+		WithContext(ctx).
+		WithTestSignalNotifyContext(t, func(parent context.Context, _ ...os.Signal) (context.Context, context.CancelFunc) {
+			return context.WithCancel(parent)
+		})
+	inv.Stdin = tty
+	inv.Stdout = tty
+	inv.Stderr = tty
+	inv.Environ = serpent.ParseEnviron(opts.Env, "")
+	go func() {
+		defer close(p.done)
+		p.err = inv.Run()
+	}()
+	return p
+}
+
+func (p *PTY) readLoop() {
+	buf := make([]byte, 4096)
+	for {
+		n, err := p.master.Read(buf)
+		p.mu.Lock()
+		_, _ = p.transcript.Write(buf[:n])
+		if err != nil {
+			// The read fails once the terminal is closed.
+			p.closed = true
+		}
+		close(p.changed)
+		p.changed = make(chan struct{})
+		p.mu.Unlock()
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Transcript returns everything the command has written to the terminal,
+// including the echo of input.
+func (p *PTY) Transcript() string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.transcript.String()
+}
+
+// ExpectString waits for s to appear in the output after the previous
+// match, and fails the test if it doesn't within the timeout. Note that
+// the terminal translates "\n" in the output to "\r\n".
+func (p *PTY) ExpectString(s string) {
+	p.t.Helper()
+
+	timer := time.NewTimer(p.timeout)
+	defer timer.Stop()
+	for {
+		p.mu.Lock()
+		out := p.transcript.String()[p.read:]
+		if i := strings.Index(out, s); i >= 0 {
+			p.read += i + len(s)
+			p.mu.Unlock()
+			return
+		}
+		changed, closed := p.changed, p.closed
+		p.mu.Unlock()
+		if closed {
+			p.t.Fatalf("terminal closed before %q was written, got:\n%s", s, out)
+		}
+
+		select {
+		case <-changed:
+		case <-timer.C:
+			p.t.Fatalf("%q was not written within %s, got:\n%s", s, p.timeout, out)
+		}
+	}
+}
+
+// Write writes s to the terminal, as if typed by the user.
+func (p *PTY) Write(s string) {
+	p.t.Helper()
+	_, err := p.master.WriteString(s)
+	if err != nil {
+		p.t.Fatalf("write to pty: %v", err)
+	}
+}
+
+// WriteLine writes s followed by a carriage return, as if typed by the user
+// and submitted with the enter key.
+func (p *PTY) WriteLine(s string) {
+	p.t.Helper()
+	p.Write(s + "\r")
+}
+
+// Resize changes the size of the terminal.
+func (p *PTY) Resize(rows, cols uint16) {
+	p.t.Helper()
+	err := setPTYSize(p.tty, rows, cols)
+	if err != nil {
+		p.t.Fatalf("resize pty: %v", err)
+	}
+}
+
+// Wait waits for the command to return and returns its error. It fails
+// the test if the command doesn't return within the timeout.
+func (p *PTY) Wait() error {
+	p.t.Helper()
+	select {
+	case <-p.done:
+		return p.err
+	case <-time.After(p.timeout):
+		p.cancel()
+		p.t.Fatalf("command did not return within %s", p.timeout)
+		return nil
+	}
+}
@@ -0,0 +1,13 @@
+//go:build !linux
+
+package serpenttest
+
+import "os"
+
+func openPTY() (master, tty *os.File, err error) {
+	return nil, nil, errPTYUnsupported
+}
+
+func setPTYSize(*os.File, uint16, uint16) error {
+	return errPTYUnsupported
+}
@@ -0,0 +1,258 @@
+package serpenttest
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/rogpeppe/go-internal/txtar"
+	"golang.org/x/xerrors"
+
+	"github.com/coder/serpent"
+)
+
+// RunScripts runs every .txtar file in dir as a subtest with RunScript.
+func RunScripts(t *testing.T, newCmd func() *serpent.Command, dir string) {
+	t.Helper()
+
+	paths, err := filepath.Glob(filepath.Join(dir, "*.txtar"))
+	if err != nil {
+		t.Fatalf("list scripts: %v", err)
+	}
+	if len(paths) == 0 {
+		t.Fatalf("no scripts in %s", dir)
+	}
+	for _, path := range paths {
+		path := path
+		t.Run(strings.TrimSuffix(filepath.Base(path), ".txtar"), func(t *testing.T) {
+			RunScript(t, newCmd, path)
+		})
+	}
+}
+
+// RunScript runs the txtar script at path. The comment section of the
+// archive holds one command per line, and the files hold input and expected
+// output. newCmd is called for every exec, as running a command sets its
+// option values.
+//
+// The commands are:
+//
+//	exec prog args...   run the command, which must succeed
+//	! exec prog args... run the command, which must fail
+//	stdout 'regex'      the last command's stdout must match regex
+//	! stdout 'regex'    the last command's stdout must not match regex
+//	stderr 'regex'      as stdout, for stderr
+//	cmp stdout file     the last command's stdout must equal the file
+//	cmp stderr file     as cmp stdout, for stderr
+//	stdin file          the next command reads file as its stdin
+//	env KEY=VALUE       set an environment variable for the next commands
+//
+// Arguments are separated by spaces, and single quotes group words. Two
+// single quotes in a row inside quotes are a literal quote. Lines starting
+// with "#" are comments. Regexes are matched in multi-line mode. When run
+// with -update, the files compared by cmp are rewritten.
+func RunScript(t *testing.T, newCmd func() *serpent.Command, path string) {
+	t.Helper()
+
+	archive, err := txtar.ParseFile(path)
+	if err != nil {
+		t.Fatalf("parse script: %v", err)
+	}
+	s := &script{
+		t:       t,
+		newCmd:  newCmd,
+		path:    path,
+		archive: archive,
+	}
+	for i, line := range strings.Split(string(archive.Comment), "\n") {
+		s.line = i + 1
+		s.exec(line)
+	}
+	if s.updated {
+		err = os.WriteFile(path, txtar.Format(archive), 0o644)
+		if err != nil {
+			t.Fatalf("update script: %v", err)
+		}
+	}
+}
+
+type script struct {
+	t       *testing.T
+	newCmd  func() *serpent.Command
+	path    string
+	archive *txtar.Archive
+	line    int
+
+	env     []string
+	stdin   string
+	last    *Result
+	updated bool
+}
+
+func (s *script) fatalf(format string, args ...any) {
+	s.t.Helper()
+	s.t.Fatalf("%s:%d: %s", s.path, s.line, fmt.Sprintf(format, args...))
+}
+
+func (s *script) file(name string) *txtar.File {
+	for i := range s.archive.Files {
+		if s.archive.Files[i].Name == name {
+			return &s.archive.Files[i]
+		}
+	}
+	return nil
+}
+
+func (s *script) output(name string) string {
+	s.t.Helper()
+	if s.last == nil {
+		s.fatalf("%s checked before any exec", name)
+	}
+	switch name {
+	case "stdout":
+		return s.last.Stdout
+	case "stderr":
+		return s.last.Stderr
+	default:
+		s.fatalf("unknown output %q", name)
+		return ""
+	}
+}
+
+func (s *script) exec(line string) {
+	s.t.Helper()
+
+	words, err := splitScriptLine(line)
+	if err != nil {
+		s.fatalf("%v", err)
+	}
+	if len(words) == 0 || strings.HasPrefix(words[0], "#") {
+		return
+	}
+	negate := words[0] == "!"
+	if negate {
+		words = words[1:]
+		if len(words) == 0 {
+			s.fatalf("missing command after !")
+		}
+	}
+	cmd, args := words[0], words[1:]
+
+	switch cmd {
+	case "exec":
+		if len(args) == 0 {
+			s.fatalf("usage: exec prog args...")
+		}
+		c := s.newCmd()
+		if args[0] != c.Name() {
+			s.fatalf("exec %q, but the command is %q", args[0], c.Name())
+		}
+		res := Run(s.t, c, RunOptions{
+			Args:  args[1:],
+			Stdin: s.stdin,
+			Env:   s.env,
+		})
+		s.last = &res
+		s.stdin = ""
+		switch {
+		case negate && res.ExitCode == 0:
+			s.fatalf("unexpected success\nstdout:\n%s", res.Stdout)
+		case !negate && res.ExitCode != 0:
+			s.fatalf("unexpected failure: %v\nstderr:\n%s", res.Err, res.Stderr)
+		}
+	case "stdout", "stderr":
+		if len(args) != 1 {
+			s.fatalf("usage: %s 'regex'", cmd)
+		}
+		re, err := regexp.Compile("(?m)" + args[0])
+		if err != nil {
+			s.fatalf("%v", err)
+		}
+		out := s.output(cmd)
+		switch matched := re.MatchString(out); {
+		case negate && matched:
+			s.fatalf("%s unexpectedly matches %q:\n%s", cmd, args[0], out)
+		case !negate && !matched:
+			s.fatalf("%s does not match %q:\n%s", cmd, args[0], out)
+		}
+	case "cmp":
+		if len(args) != 2 || negate {
+			s.fatalf("usage: cmp stdout|stderr file")
+		}
+		out := s.output(args[0])
+		f := s.file(args[1])
+		if f == nil {
+			s.fatalf("no file %q in script", args[1])
+		}
+		if updating() {
+			f.Data = []byte(out)
+			s.updated = true
+			return
+		}
+		if string(f.Data) != out {
+			s.fatalf("%s does not match %s, run with -%s to update it\ngot:\n%s\nwant:\n%s", args[0], args[1], UpdateFlag, out, f.Data)
+		}
+	case "stdin":
+		if len(args) != 1 || negate {
+			s.fatalf("usage: stdin file")
+		}
+		f := s.file(args[0])
+		if f == nil {
+			s.fatalf("no file %q in script", args[0])
+		}
+		s.stdin = string(f.Data)
+	case "env":
+		if negate {
+			s.fatalf("usage: env KEY=VALUE...")
+		}
+		for _, kv := range args {
+			if !strings.Contains(kv, "=") {
+				s.fatalf("usage: env KEY=VALUE...")
+			}
+			s.env = append(s.env, kv)
+		}
+	default:
+		s.fatalf("unknown command %q", cmd)
+	}
+}
+
+// splitScriptLine splits line into words at spaces. Single quotes group
+// words, and two single quotes in a row inside them are a literal quote.
+func splitScriptLine(line string) ([]string, error) {
+	var (
+		words  []string
+		word   strings.Builder
+		inWord bool
+		quoted bool
+	)
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case quoted && c == '\'' && i+1 < len(line) && line[i+1] == '\'':
+			_ = word.WriteByte('\'')
+			i++
+		case c == '\'':
+			quoted = !quoted
+			inWord = true
+		case !quoted && (c == ' ' || c == '\t'):
+			if inWord {
+				words = append(words, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			_ = word.WriteByte(c)
+			inWord = true
+		}
+	}
+	if quoted {
+		return nil, xerrors.New("unterminated quote")
+	}
+	if inWord {
+		words = append(words, word.String())
+	}
+	return words, nil
+}
@@ -0,0 +1,146 @@
+// Package serpenttest helps test serpent commands.
+//
+// Run runs an invocation with captured output, scripted stdin and a fake
+// environment. Golden compares output with golden files, which are
+// rewritten when tests run with -update. RunScript runs txtar scripts of
+// commands and assertions, for table-driven CLI tests. StartPTY runs a
+// command attached to a pseudo-terminal, for testing interactive commands.
+package serpenttest
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/coder/serpent"
+)
+
+// DefaultTimeout is how long Run waits for a command when
+// RunOptions.Timeout is unset.
+const DefaultTimeout = 10 * time.Second
+
+// RunOptions configures Run.
+type RunOptions struct {
+	// Args are the arguments passed to the command, without the program
+	// name.
+	Args []string
+	// Stdin is read by the command as its standard input.
+	Stdin string
+	// Env is the command's environment, as "KEY=VALUE" pairs. The
+	// environment of the test process is not inherited.
+	Env []string
+	// Timeout is how long the command may run. Its context is canceled at
+	// the deadline, and the test fails if it hasn't returned shortly
+	// after. If zero, DefaultTimeout is used.
+	Timeout time.Duration
+	// ExitCode maps the error returned by the command to an exit code. If
+	// nil, DefaultExitCode is used.
+	ExitCode func(err error) int
+	// Interactive makes prompts read Stdin as if it were a terminal, see
+	// serpent.Invocation.WithInteractiveStdin.
+	Interactive bool
+}
+
+// Result is the outcome of running a command.
+type Result struct {
+	Stdout   string
+	Stderr   string
+	Err      error
+	ExitCode int
+}
+
+// DefaultExitCode maps nil to 0, errors with an ExitCode method, such as
+// *exec.ExitError, to the code it returns, and other errors to 1.
+func DefaultExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var coder interface{ ExitCode() int }
+	if errors.As(err, &coder) {
+		return coder.ExitCode()
+	}
+	return 1
+}
+
+// Run runs cmd with opts and returns its output. Signal handling set up
+// through Invocation.SignalNotifyContext is replaced so that the test
+// process's signals are never captured.
+func Run(t testing.TB, cmd *serpent.Command, opts RunOptions) Result {
+	t.Helper()
+
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+	exitCode := opts.ExitCode
+	if exitCode == nil {
+		exitCode = DefaultExitCode
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var stdout, stderr syncBuffer
+	inv := cmd.Invoke(opts.Args...).
+		WithContext(ctx).
+		WithTestSignalNotifyContext(t, func(parent context.Context, _ ...os.Signal) (context.Context, context.CancelFunc) {
+			return context.WithCancel(parent)
+		})
+	inv.Stdout = &stdout
+	inv.Stderr = &stderr
+	inv.Stdin = io.NopCloser(strings.NewReader(opts.Stdin))
+	inv.Environ = serpent.ParseEnviron(opts.Env, "")
+	if opts.Interactive {
+		inv = inv.WithInteractiveStdin()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- inv.Run()
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		// Give the command a moment to notice its context was canceled.
+		select {
+		case err = <-done:
+		case <-time.After(time.Second):
+			t.Fatalf("command %q did not return within %s\nstdout:\n%s\nstderr:\n%s",
+				strings.Join(opts.Args, " "), timeout, stdout.String(), stderr.String())
+		}
+	}
+
+	return Result{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		Err:      err,
+		ExitCode: exitCode(err),
+	}
+}
+
+// syncBuffer is a bytes.Buffer that can be read while the command is
+// still writing to it.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
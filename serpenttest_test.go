@@ -0,0 +1,172 @@
+package serpent_test
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/term"
+	"golang.org/x/xerrors"
+
+	"github.com/coder/serpent"
+	"github.com/coder/serpent/serpenttest"
+)
+
+func init() {
+	flag.BoolVar(&serpenttest.Update, serpenttest.UpdateFlag, false, "update golden files")
+}
+
+type exitError int
+
+func (e exitError) Error() string { return fmt.Sprintf("exit %d", int(e)) }
+
+func (e exitError) ExitCode() int { return int(e) }
+
+func echoCommand() *serpent.Command {
+	var (
+		upper bool
+		code  int64
+	)
+	return &serpent.Command{
+		Use: "echo",
+		Options: serpent.OptionSet{
+			{
+				Name:  "upper",
+				Flag:  "upper",
+				Env:   "ECHO_UPPER",
+				Value: serpent.BoolOf(&upper),
+			},
+			{
+				Name:  "exit",
+				Flag:  "exit",
+				Value: serpent.Int64Of(&code),
+			},
+		},
+		Handler: func(inv *serpent.Invocation) error {
+			text := strings.Join(inv.Args, " ")
+			if text == "-" {
+				byt, err := io.ReadAll(inv.Stdin)
+				if err != nil {
+					return err
+				}
+				text = string(byt)
+			}
+			if upper {
+				text = strings.ToUpper(text)
+			}
+			_, _ = fmt.Fprintln(inv.Stdout, text)
+			if code != 0 {
+				_, _ = fmt.Fprintln(inv.Stderr, "failing")
+				return exitError(code)
+			}
+			return nil
+		},
+		Children: []*serpent.Command{
+			{
+				Use: "hang",
+				Handler: func(inv *serpent.Invocation) error {
+					<-inv.Context().Done()
+					return inv.Context().Err()
+				},
+			},
+		},
+	}
+}
+
+func TestRun(t *testing.T) {
+	t.Parallel()
+
+	t.Run("Output", func(t *testing.T) {
+		t.Parallel()
+		res := serpenttest.Run(t, echoCommand(), serpenttest.RunOptions{
+			Args: []string{"hello", "world"},
+		})
+		require.NoError(t, res.Err)
+		require.Equal(t, "hello world\n", res.Stdout)
+		require.Zero(t, res.ExitCode)
+	})
+
+	t.Run("StdinAndEnv", func(t *testing.T) {
+		t.Parallel()
+		res := serpenttest.Run(t, echoCommand(), serpenttest.RunOptions{
+			Args:  []string{"-"},
+			Stdin: "from stdin",
+			Env:   []string{"ECHO_UPPER=true"},
+		})
+		require.NoError(t, res.Err)
+		require.Equal(t, "FROM STDIN\n", res.Stdout)
+	})
+
+	t.Run("ExitCode", func(t *testing.T) {
+		t.Parallel()
+		res := serpenttest.Run(t, echoCommand(), serpenttest.RunOptions{
+			Args: []string{"--exit", "3", "x"},
+		})
+		require.Error(t, res.Err)
+		require.Equal(t, 3, res.ExitCode)
+		require.Equal(t, "failing\n", res.Stderr)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		t.Parallel()
+		res := serpenttest.Run(t, echoCommand(), serpenttest.RunOptions{
+			Args:    []string{"hang"},
+			Timeout: 10 * time.Millisecond,
+		})
+		require.Error(t, res.Err)
+		require.Equal(t, 1, res.ExitCode)
+	})
+}
+
+func TestGolden(t *testing.T) {
+	t.Parallel()
+	res := serpenttest.Run(t, echoCommand(), serpenttest.RunOptions{
+		Args: []string{"golden", "output"},
+	})
+	serpenttest.Golden(t, "serpenttest_echo", []byte(res.Stdout))
+}
+
+func TestRunScripts(t *testing.T) {
+	t.Parallel()
+	serpenttest.RunScripts(t, echoCommand, "testdata/serpenttest")
+}
+
+func TestPTY(t *testing.T) {
+	t.Parallel()
+
+	cmd := &serpent.Command{
+		Use: "prompt",
+		Handler: func(inv *serpent.Invocation) error {
+			stdin, ok := inv.Stdin.(*os.File)
+			if !ok || !term.IsTerminal(int(stdin.Fd())) {
+				return xerrors.New("stdin is not a terminal")
+			}
+			width, _, err := term.GetSize(int(stdin.Fd()))
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(inv.Stdout, "width=%d\n", width)
+			_, _ = fmt.Fprint(inv.Stdout, "name? ")
+			line, err := bufio.NewReader(inv.Stdin).ReadString('\n')
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(inv.Stdout, "hello %s\n", strings.TrimSpace(line))
+			return nil
+		},
+	}
+
+	p := serpenttest.StartPTY(t, cmd, serpenttest.PTYOptions{Cols: 120})
+	p.ExpectString("width=120")
+	p.ExpectString("name? ")
+	p.WriteLine("gopher")
+	p.ExpectString("hello gopher")
+	require.NoError(t, p.Wait())
+	require.Contains(t, p.Transcript(), "gopher\r\n")
+}
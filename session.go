@@ -0,0 +1,75 @@
+package serpent
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+)
+
+// session holds values shared by the invocations of an interactive shell
+// or batch, which run in its context.
+type session struct {
+	mu     sync.Mutex
+	values map[any]any
+	// order is the order in which values were stored, so that they are
+	// closed in reverse.
+	order []any
+}
+
+type sessionContextKey struct{}
+
+// withSession returns a context carrying a new session, and a function
+// that ends it.
+func withSession(ctx context.Context) (context.Context, func() error) {
+	s := &session{values: make(map[any]any)}
+	return context.WithValue(ctx, sessionContextKey{}, s), s.close
+}
+
+// close closes the session's values that implement io.Closer, in reverse
+// order of creation.
+func (s *session) close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var err error
+	for i := len(s.order) - 1; i >= 0; i-- {
+		if c, ok := s.values[s.order[i]].(io.Closer); ok {
+			err = errors.Join(err, c.Close())
+		}
+	}
+	s.values = nil
+	s.order = nil
+	return err
+}
+
+// SessionValue returns the value stored under key in the session of ctx,
+// calling init to create it on first use. Shells and batches run all their
+// commands in one session, so middleware can use it to set up an expensive
+// client once and reuse it for every command:
+//
+//	client, err := serpent.SessionValue(inv.Context(), clientKey{}, dial)
+//
+// Values that implement io.Closer are closed when the session ends. Outside
+// of a session, init is called every time. Errors from init aren't stored.
+func SessionValue[T any](ctx context.Context, key any, init func() (T, error)) (T, error) {
+	s, ok := ctx.Value(sessionContextKey{}).(*session)
+	if !ok {
+		return init()
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if v, ok := s.values[key].(T); ok {
+		return v, nil
+	}
+	v, err := init()
+	if err != nil {
+		return v, err
+	}
+	if s.values != nil {
+		s.values[key] = v
+		s.order = append(s.order, key)
+	}
+	return v, nil
+}
@@ -0,0 +1,77 @@
+package serpent
+
+import (
+	"strings"
+
+	"golang.org/x/xerrors"
+)
+
+// splitArgs splits line into words following POSIX shell quoting rules.
+// Words are separated by unquoted whitespace, single quotes preserve their
+// contents literally, and backslashes escape the next character outside of
+// quotes and '$', '`', '"', '\' and newlines inside double quotes. Nothing
+// is expanded.
+//
+// In partial mode, line is treated as an incomplete command line being
+// typed: an unterminated quote is closed implicitly, and trailing
+// whitespace yields a final empty word for the word about to be typed.
+func splitArgs(line string, partial bool) ([]string, error) {
+	var (
+		args    []string
+		word    strings.Builder
+		inWord  bool
+		quote   rune
+		escaped bool
+	)
+	for _, r := range line {
+		switch {
+		case escaped:
+			if quote == '"' && !strings.ContainsRune("$`\"\\\n", r) {
+				_, _ = word.WriteRune('\\')
+			}
+			if r != '\n' {
+				_, _ = word.WriteRune(r)
+			}
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped = true
+			inWord = true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+				continue
+			}
+			_, _ = word.WriteRune(r)
+		case r == '\'' || r == '"':
+			quote = r
+			inWord = true
+		case r == ' ' || r == '\t' || r == '\n':
+			if inWord {
+				args = append(args, word.String())
+				word.Reset()
+				inWord = false
+			}
+		default:
+			_, _ = word.WriteRune(r)
+			inWord = true
+		}
+	}
+
+	if !partial {
+		if quote != 0 {
+			return nil, xerrors.Errorf("unterminated %c quote", quote)
+		}
+		if escaped {
+			return nil, xerrors.Errorf("trailing backslash")
+		}
+	}
+	switch {
+	case inWord:
+		args = append(args, word.String())
+	case partial:
+		// The cursor is after whitespace, so a new, empty word is
+		// being typed.
+		args = append(args, "")
+	}
+	return args, nil
+}
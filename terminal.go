@@ -0,0 +1,133 @@
+package serpent
+
+import (
+	"io"
+
+	"github.com/muesli/termenv"
+	"golang.org/x/term"
+)
+
+const (
+	// defaultTerminalWidth and defaultTerminalHeight are the size assumed
+	// when the output isn't a terminal or its size can't be queried.
+	defaultTerminalWidth  = 80
+	defaultTerminalHeight = 24
+)
+
+// Terminal describes the terminal an invocation's output is displayed on.
+// Help rendering and warnings consult it instead of the host process, so
+// that concurrent invocations with different outputs render independently.
+type Terminal interface {
+	// Width returns the number of columns of the terminal.
+	Width() int
+	// Height returns the number of rows of the terminal.
+	Height() int
+	// IsTTY reports whether the output is an interactive terminal.
+	IsTTY() bool
+	// ColorProfile returns the colors the output supports. It is
+	// termenv.Ascii when colors must not be used.
+	ColorProfile() termenv.Profile
+}
+
+// StaticTerminal is a Terminal with fixed properties. It's useful in tests
+// and when output is forwarded to a terminal the process can't query, such
+// as over SSH.
+type StaticTerminal struct {
+	// Columns and Rows are the size of the terminal. If zero, they are
+	// 80 and 24.
+	Columns, Rows int
+	TTY           bool
+	Profile       termenv.Profile
+}
+
+var _ Terminal = StaticTerminal{}
+
+func (t StaticTerminal) Width() int {
+	if t.Columns <= 0 {
+		return defaultTerminalWidth
+	}
+	return t.Columns
+}
+
+func (t StaticTerminal) Height() int {
+	if t.Rows <= 0 {
+		return defaultTerminalHeight
+	}
+	return t.Rows
+}
+
+func (t StaticTerminal) IsTTY() bool {
+	return t.TTY
+}
+
+func (t StaticTerminal) ColorProfile() termenv.Profile {
+	return t.Profile
+}
+
+// DetectTerminal returns the Terminal that w is displayed on. w is a
+// terminal if it's a file connected to a TTY, in which case its size is
+// queried on every call so that resizes are honored.
+//
+// The color profile is derived from TERM and COLORTERM in env. NO_COLOR
+// disables colors, and CLICOLOR_FORCE enables them even when w isn't a
+// terminal.
+func DetectTerminal(w io.Writer, env Environ) Terminal {
+	t := &fileTerminal{fd: -1}
+	if f, ok := w.(interface{ Fd() uintptr }); ok && term.IsTerminal(int(f.Fd())) {
+		t.fd = int(f.Fd())
+	}
+	// NewOutput resolves the profile from the environment, honoring
+	// NO_COLOR and CLICOLOR_FORCE.
+	t.profile = termenv.NewOutput(w,
+		termenv.WithEnvironment(termEnviron(env)),
+		termenv.WithTTY(t.IsTTY()),
+	).Profile
+	return t
+}
+
+// fileTerminal is a Terminal backed by a file descriptor, or by nothing
+// if fd is negative.
+type fileTerminal struct {
+	fd      int
+	profile termenv.Profile
+}
+
+func (t *fileTerminal) size() (width, height int) {
+	if !t.IsTTY() {
+		return defaultTerminalWidth, defaultTerminalHeight
+	}
+	width, height, err := term.GetSize(t.fd)
+	if err != nil || width <= 0 || height <= 0 {
+		return defaultTerminalWidth, defaultTerminalHeight
+	}
+	return width, height
+}
+
+func (t *fileTerminal) Width() int {
+	width, _ := t.size()
+	return width
+}
+
+func (t *fileTerminal) Height() int {
+	_, height := t.size()
+	return height
+}
+
+func (t *fileTerminal) IsTTY() bool {
+	return t.fd >= 0
+}
+
+func (t *fileTerminal) ColorProfile() termenv.Profile {
+	return t.profile
+}
+
+// termEnviron adapts Environ to termenv's environment interface.
+type termEnviron Environ
+
+func (e termEnviron) Environ() []string {
+	return Environ(e).ToOS()
+}
+
+func (e termEnviron) Getenv(name string) string {
+	return Environ(e).Get(name)
+}
@@ -0,0 +1,118 @@
+package serpent_test
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/muesli/termenv"
+	"github.com/stretchr/testify/require"
+
+	serpent "github.com/coder/serpent"
+	"github.com/coder/serpent/serpenttest"
+)
+
+func TestDetectTerminal(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		env     []string
+		profile termenv.Profile
+	}{
+		{name: "Default", profile: termenv.Ascii},
+		{name: "Force", env: []string{"CLICOLOR_FORCE=1"}, profile: termenv.ANSI},
+		{name: "ForceDisabled", env: []string{"CLICOLOR_FORCE=0"}, profile: termenv.Ascii},
+		{name: "NoColor", env: []string{"CLICOLOR_FORCE=1", "NO_COLOR=1"}, profile: termenv.Ascii},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			term := serpent.DetectTerminal(&bytes.Buffer{}, serpent.ParseEnviron(tt.env, ""))
+			require.False(t, term.IsTTY())
+			require.Equal(t, 80, term.Width())
+			require.Equal(t, 24, term.Height())
+			require.Equal(t, tt.profile, term.ColorProfile())
+		})
+	}
+}
+
+func TestInvocationTerminal(t *testing.T) {
+	t.Parallel()
+
+	cmd := func() *serpent.Command {
+		return &serpent.Command{
+			Use:   "root",
+			Short: "Report the terminal.",
+			Long: "A command with a long description that is wrapped to the " +
+				"width of the terminal the help is rendered on.",
+			Handler: func(inv *serpent.Invocation) error {
+				term := inv.Terminal()
+				_, _ = fmt.Fprintf(inv.Stdout, "tty=%v size=%dx%d\n", term.IsTTY(), term.Width(), term.Height())
+				return nil
+			},
+		}
+	}
+
+	t.Run("Override", func(t *testing.T) {
+		t.Parallel()
+
+		inv := cmd().Invoke().WithTerminal(serpent.StaticTerminal{
+			Columns: 100,
+			Rows:    30,
+			TTY:     true,
+		})
+		stdio := fakeIO(inv)
+		require.NoError(t, inv.Run())
+		require.Equal(t, "tty=true size=100x30\n", stdio.Stdout.String())
+	})
+
+	t.Run("HelpWidth", func(t *testing.T) {
+		t.Parallel()
+
+		inv := cmd().Invoke("--help").WithTerminal(serpent.StaticTerminal{Columns: 40})
+		stdio := fakeIO(inv)
+		require.NoError(t, inv.Run())
+		for _, line := range strings.Split(stdio.Stdout.String(), "\n") {
+			require.LessOrEqual(t, len(line), 40, "line %q", line)
+		}
+	})
+
+	t.Run("HelpColor", func(t *testing.T) {
+		t.Parallel()
+
+		inv := cmd().Invoke("--help")
+		stdio := fakeIO(inv)
+		require.NoError(t, inv.Run())
+		require.NotContains(t, stdio.Stdout.String(), "\x1b[")
+
+		inv = cmd().Invoke("--help").WithTerminal(serpent.StaticTerminal{Profile: termenv.ANSI})
+		stdio = fakeIO(inv)
+		require.NoError(t, inv.Run())
+		require.Contains(t, stdio.Stdout.String(), "\x1b[")
+	})
+
+	t.Run("HelpEnviron", func(t *testing.T) {
+		t.Parallel()
+
+		inv := cmd().Invoke("--help")
+		stdio := fakeIO(inv)
+		inv.Environ.Set("CLICOLOR_FORCE", "1")
+		require.NoError(t, inv.Run())
+		require.Contains(t, stdio.Stdout.String(), "\x1b[")
+	})
+
+	t.Run("PTY", func(t *testing.T) {
+		t.Parallel()
+
+		pty := serpenttest.StartPTY(t, cmd(), serpenttest.PTYOptions{
+			Rows: 30,
+			Cols: 100,
+		})
+		pty.ExpectString("tty=true size=100x30")
+		require.NoError(t, pty.Wait())
+	})
+}
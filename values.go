@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"reflect"
 	"regexp"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -26,6 +27,49 @@ type NoOptDefValuer interface {
 	NoOptDefValue() string
 }
 
+// ValueCloner is implemented by values that can copy themselves into new
+// storage. Invocations with isolated options parse into clones, so that
+// they don't share state with other invocations of the same command.
+//
+// All the values in this package implement it. Custom pflag.Value types
+// must implement it too to be used with WithIsolatedOptions: Run refuses
+// to start an isolated invocation of a tree that has a value without it.
+type ValueCloner interface {
+	CloneValue() (pflag.Value, error)
+}
+
+// cloneValue returns a copy of v backed by new storage.
+func cloneValue(v pflag.Value) (pflag.Value, error) {
+	if v == nil {
+		return nil, nil
+	}
+	c, ok := v.(ValueCloner)
+	if !ok {
+		return nil, xerrors.Errorf("value of type %T can't be cloned", v)
+	}
+	return c.CloneValue()
+}
+
+// cloneValueAs is cloneValue for the values wrapped by generic types.
+func cloneValueAs[T pflag.Value](v T) (T, error) {
+	var zero T
+	c, err := cloneValue(v)
+	if err != nil {
+		return zero, err
+	}
+	t, ok := c.(T)
+	if !ok {
+		return zero, xerrors.Errorf("clone of %T is a %T", v, c)
+	}
+	return t, nil
+}
+
+// clonePtr returns a pointer to a shallow copy of *v.
+func clonePtr[T any](v *T) *T {
+	c := *v
+	return &c
+}
+
 // Validator is a wrapper around a pflag.Value that allows for validation
 // of the value after or before it has been set.
 type Validator[T pflag.Value] struct {
@@ -60,6 +104,14 @@ func (i *Validator[T]) Type() string {
 	return i.Value.Type()
 }
 
+func (i *Validator[T]) CloneValue() (pflag.Value, error) {
+	v, err := cloneValueAs(i.Value)
+	if err != nil {
+		return nil, err
+	}
+	return &Validator[T]{Value: v, validate: i.validate}, nil
+}
+
 func (i *Validator[T]) MarshalYAML() (interface{}, error) {
 	m, ok := any(i.Value).(yaml.Marshaler)
 	if !ok {
@@ -109,6 +161,10 @@ func (Int64) Type() string {
 	return "int"
 }
 
+func (i *Int64) CloneValue() (pflag.Value, error) {
+	return clonePtr(i), nil
+}
+
 type Float64 float64
 
 func Float64Of(f *float64) *Float64 {
@@ -133,6 +189,10 @@ func (Float64) Type() string {
 	return "float64"
 }
 
+func (f *Float64) CloneValue() (pflag.Value, error) {
+	return clonePtr(f), nil
+}
+
 type Bool bool
 
 func BoolOf(b *bool) *Bool {
@@ -165,6 +225,10 @@ func (Bool) Type() string {
 	return "bool"
 }
 
+func (b *Bool) CloneValue() (pflag.Value, error) {
+	return clonePtr(b), nil
+}
+
 type String string
 
 func StringOf(s *string) *String {
@@ -192,6 +256,10 @@ func (String) Type() string {
 	return "string"
 }
 
+func (s *String) CloneValue() (pflag.Value, error) {
+	return clonePtr(s), nil
+}
+
 var (
 	_ pflag.SliceValue = &StringArray{}
 	_ pflag.Value      = &StringArray{}
@@ -256,6 +324,11 @@ func (StringArray) Type() string {
 	return "string-array"
 }
 
+func (s *StringArray) CloneValue() (pflag.Value, error) {
+	c := StringArray(slices.Clone(*s))
+	return &c, nil
+}
+
 type Duration time.Duration
 
 func DurationOf(d *time.Duration) *Duration {
@@ -289,6 +362,10 @@ func (Duration) Type() string {
 	return "duration"
 }
 
+func (d *Duration) CloneValue() (pflag.Value, error) {
+	return clonePtr(d), nil
+}
+
 func (d *Duration) MarshalYAML() (interface{}, error) {
 	return yaml.Node{
 		Kind:  yaml.ScalarNode,
@@ -348,6 +425,10 @@ func (*URL) Type() string {
 	return "url"
 }
 
+func (u *URL) CloneValue() (pflag.Value, error) {
+	return clonePtr(u), nil
+}
+
 func (u *URL) Value() *url.URL {
 	return (*url.URL)(u)
 }
@@ -409,6 +490,10 @@ func (*HostPort) Type() string {
 	return "host:port"
 }
 
+func (hp *HostPort) CloneValue() (pflag.Value, error) {
+	return clonePtr(hp), nil
+}
+
 var (
 	_ yaml.Marshaler   = new(Struct[struct{}])
 	_ yaml.Unmarshaler = new(Struct[struct{}])
@@ -464,6 +549,21 @@ func (s *Struct[T]) Type() string {
 	return fmt.Sprintf("struct[%T]", s.Value)
 }
 
+// CloneValue copies the struct through its YAML encoding, so that maps and
+// slices aren't shared with the clone.
+func (s *Struct[T]) CloneValue() (pflag.Value, error) {
+	byt, err := yaml.Marshal(s.Value)
+	if err != nil {
+		return nil, xerrors.Errorf("marshal: %w", err)
+	}
+	var c Struct[T]
+	err = yaml.Unmarshal(byt, &c.Value)
+	if err != nil {
+		return nil, xerrors.Errorf("unmarshal: %w", err)
+	}
+	return &c, nil
+}
+
 // nolint:revive
 func (s *Struct[T]) MarshalJSON() ([]byte, error) {
 	return json.Marshal(s.Value)
@@ -493,6 +593,10 @@ func (discardValue) Type() string {
 	return "discard"
 }
 
+func (discardValue) CloneValue() (pflag.Value, error) {
+	return DiscardValue, nil
+}
+
 func (discardValue) UnmarshalJSON([]byte) error {
 	return nil
 }
@@ -514,6 +618,11 @@ func (jsonValue) Type() string {
 	return "json"
 }
 
+func (j *jsonValue) CloneValue() (pflag.Value, error) {
+	c := jsonValue(slices.Clone(*j))
+	return &c, nil
+}
+
 func (j *jsonValue) UnmarshalJSON(data []byte) error {
 	if j == nil {
 		return xerrors.New("json.RawMessage: UnmarshalJSON on nil pointer")
@@ -524,8 +633,97 @@ func (j *jsonValue) UnmarshalJSON(data []byte) error {
 
 var _ pflag.Value = (*Enum)(nil)
 
+// EnumChoice describes a single choice of an Enum or EnumArray.
+type EnumChoice struct {
+	// Value is the canonical spelling of the choice.
+	Value string `json:"value"`
+	// Description is shown in help and in completions for shells that
+	// support descriptions.
+	Description string `json:"description,omitempty"`
+	// Aliases are alternative spellings that are accepted as input. They
+	// are canonicalized to Value.
+	Aliases []string `json:"aliases,omitempty"`
+	// Deprecated choices are still accepted, but hidden from completion
+	// and marked in help.
+	Deprecated bool `json:"deprecated,omitempty"`
+}
+
+// enumChoices returns details for each of choices, taken from the detail
+// with the same value, if any. If choices is empty, they are the values of
+// details.
+func enumChoices(choices []string, details []EnumChoice) []EnumChoice {
+	if len(choices) == 0 {
+		return details
+	}
+	out := make([]EnumChoice, 0, len(choices))
+	for _, c := range choices {
+		i := slices.IndexFunc(details, func(d EnumChoice) bool { return d.Value == c })
+		if i >= 0 {
+			out = append(out, details[i])
+			continue
+		}
+		out = append(out, EnumChoice{Value: c})
+	}
+	return out
+}
+
+// checkEnumDetails returns an error if details describe a value that isn't
+// one of choices, as it couldn't be chosen.
+func checkEnumDetails(choices []string, details []EnumChoice) error {
+	if len(choices) == 0 {
+		return nil
+	}
+	for _, d := range details {
+		if !slices.Contains(choices, d.Value) {
+			return xerrors.Errorf("enum details describe %q, which is not one of the choices %v", d.Value, choices)
+		}
+	}
+	return nil
+}
+
+// lookupEnumChoice finds the canonical spelling of v amongst choices and
+// their aliases, ignoring case.
+func lookupEnumChoice(choices []string, details []EnumChoice, v string) (string, bool) {
+	for _, c := range enumChoices(choices, details) {
+		if strings.EqualFold(v, c.Value) {
+			return c.Value, true
+		}
+		for _, a := range c.Aliases {
+			if strings.EqualFold(v, a) {
+				return c.Value, true
+			}
+		}
+	}
+	return "", false
+}
+
+// visibleEnumChoices returns the choices that aren't deprecated, which are
+// the ones offered in help and completion.
+func visibleEnumChoices(choices []string, details []EnumChoice) []EnumChoice {
+	var out []EnumChoice
+	for _, c := range enumChoices(choices, details) {
+		if c.Deprecated {
+			continue
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+func enumChoiceValues(details []EnumChoice) []string {
+	values := make([]string, 0, len(details))
+	for _, c := range details {
+		values = append(values, c.Value)
+	}
+	return values
+}
+
 type Enum struct {
 	Choices []string
+	// Details optionally describes the Choices, matched by value. If
+	// Choices is empty, the choices are the values of Details. Use
+	// EnumOfChoices to set both.
+	Details []EnumChoice `json:",omitempty"`
 	Value   *string
 }
 
@@ -538,18 +736,43 @@ func EnumOf(v *string, choices ...string) *Enum {
 	}
 }
 
+// EnumOfChoices is like EnumOf, but each choice may carry a description,
+// aliases and a deprecation marker.
+func EnumOfChoices(v *string, choices ...EnumChoice) *Enum {
+	choices = append([]EnumChoice{}, choices...)
+	return &Enum{
+		Choices: enumChoiceValues(choices),
+		Details: choices,
+		Value:   v,
+	}
+}
+
+// ChoiceDetails returns the details of every choice, in order.
+func (e *Enum) ChoiceDetails() []EnumChoice {
+	return enumChoices(e.Choices, e.Details)
+}
+
+func (e *Enum) choiceValues() []string {
+	return enumChoiceValues(e.ChoiceDetails())
+}
+
 func (e *Enum) Set(v string) error {
-	for _, c := range e.Choices {
-		if strings.EqualFold(v, c) {
-			*e.Value = v
-			return nil
-		}
+	c, ok := lookupEnumChoice(e.Choices, e.Details, v)
+	if !ok {
+		return xerrors.Errorf("invalid choice: %s, should be one of %v", v, e.choiceValues())
 	}
-	return xerrors.Errorf("invalid choice: %s, should be one of %v", v, e.Choices)
+	*e.Value = c
+	return nil
 }
 
 func (e *Enum) Type() string {
-	return fmt.Sprintf("enum[%v]", strings.Join(e.Choices, "\\|"))
+	return fmt.Sprintf("enum[%v]", strings.Join(e.choiceValues(), "\\|"))
+}
+
+func (e *Enum) CloneValue() (pflag.Value, error) {
+	c := *e
+	c.Value = clonePtr(e.Value)
+	return &c, nil
 }
 
 func (e *Enum) String() string {
@@ -623,6 +846,10 @@ func (Regexp) Type() string {
 	return "regexp"
 }
 
+func (r *Regexp) CloneValue() (pflag.Value, error) {
+	return clonePtr(r), nil
+}
+
 var _ pflag.Value = (*YAMLConfigPath)(nil)
 
 // YAMLConfigPath is a special value type that encodes a path to a YAML
@@ -642,22 +869,38 @@ func (*YAMLConfigPath) Type() string {
 	return "yaml-config-path"
 }
 
+func (p *YAMLConfigPath) CloneValue() (pflag.Value, error) {
+	return clonePtr(p), nil
+}
+
 var _ pflag.SliceValue = (*EnumArray)(nil)
 var _ pflag.Value = (*EnumArray)(nil)
 
 type EnumArray struct {
 	Choices []string
+	// Details optionally describes the Choices, matched by value. If
+	// Choices is empty, the choices are the values of Details. Use
+	// EnumArrayOfChoices to set both.
+	Details []EnumChoice `json:",omitempty"`
 	Value   *[]string
 }
 
+// ChoiceDetails returns the details of every choice, in order.
+func (e *EnumArray) ChoiceDetails() []EnumChoice {
+	return enumChoices(e.Choices, e.Details)
+}
+
+func (e *EnumArray) choiceValues() []string {
+	return enumChoiceValues(e.ChoiceDetails())
+}
+
 func (e *EnumArray) Append(s string) error {
-	for _, c := range e.Choices {
-		if strings.EqualFold(s, c) {
-			*e.Value = append(*e.Value, s)
-			return nil
-		}
+	c, ok := lookupEnumChoice(e.Choices, e.Details, s)
+	if !ok {
+		return xerrors.Errorf("invalid choice: %s, should be one of %v", s, e.choiceValues())
 	}
-	return xerrors.Errorf("invalid choice: %s, should be one of %v", s, e.Choices)
+	*e.Value = append(*e.Value, c)
+	return nil
 }
 
 func (e *EnumArray) GetSlice() []string {
@@ -665,19 +908,15 @@ func (e *EnumArray) GetSlice() []string {
 }
 
 func (e *EnumArray) Replace(ss []string) error {
+	canonical := make([]string, 0, len(ss))
 	for _, s := range ss {
-		found := false
-		for _, c := range e.Choices {
-			if strings.EqualFold(s, c) {
-				found = true
-				break
-			}
-		}
-		if !found {
-			return xerrors.Errorf("invalid choice: %s, should be one of %v", s, e.Choices)
+		c, ok := lookupEnumChoice(e.Choices, e.Details, s)
+		if !ok {
+			return xerrors.Errorf("invalid choice: %s, should be one of %v", s, e.choiceValues())
 		}
+		canonical = append(canonical, c)
 	}
-	*e.Value = ss
+	*e.Value = canonical
 	return nil
 }
 
@@ -704,7 +943,14 @@ func (e *EnumArray) String() string {
 }
 
 func (e *EnumArray) Type() string {
-	return fmt.Sprintf("enum-array[%v]", strings.Join(e.Choices, "\\|"))
+	return fmt.Sprintf("enum-array[%v]", strings.Join(e.choiceValues(), "\\|"))
+}
+
+func (e *EnumArray) CloneValue() (pflag.Value, error) {
+	c := *e
+	v := slices.Clone(*e.Value)
+	c.Value = &v
+	return &c, nil
 }
 
 func EnumArrayOf(v *[]string, choices ...string) *EnumArray {
@@ -714,3 +960,14 @@ func EnumArrayOf(v *[]string, choices ...string) *EnumArray {
 		Value:   v,
 	}
 }
+
+// EnumArrayOfChoices is like EnumArrayOf, but each choice may carry a
+// description, aliases and a deprecation marker.
+func EnumArrayOfChoices(v *[]string, choices ...EnumChoice) *EnumArray {
+	choices = append([]EnumChoice{}, choices...)
+	return &EnumArray{
+		Choices: enumChoiceValues(choices),
+		Details: choices,
+		Value:   v,
+	}
+}
@@ -1,9 +1,11 @@
 package serpent_test
 
 import (
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/require"
 
 	serpent "github.com/coder/serpent"
@@ -141,3 +143,124 @@ func TestDurationOf(t *testing.T) {
 	*d = serpent.Duration(newVal)
 	require.Equal(t, newVal, time.Duration(td))
 }
+
+func TestEnum(t *testing.T) {
+	t.Parallel()
+
+	choices := []serpent.EnumChoice{
+		{Value: "table", Description: "Render as a table."},
+		{Value: "json", Description: "Render as JSON.", Aliases: []string{"js"}},
+		{Value: "yml", Deprecated: true},
+	}
+
+	t.Run("Canonicalize", func(t *testing.T) {
+		t.Parallel()
+		var v string
+		e := serpent.EnumOfChoices(&v, choices...)
+		require.Equal(t, []string{"table", "json", "yml"}, e.Choices)
+
+		require.NoError(t, e.Set("TABLE"))
+		require.Equal(t, "table", v)
+		require.NoError(t, e.Set("JS"))
+		require.Equal(t, "json", v)
+		require.NoError(t, e.Set("yml"))
+		require.Equal(t, "yml", v)
+		require.Error(t, e.Set("xml"))
+
+		plain := serpent.EnumOf(&v, "Foo", "bar")
+		require.NoError(t, plain.Set("foo"))
+		require.Equal(t, "Foo", v)
+	})
+
+	t.Run("Array", func(t *testing.T) {
+		t.Parallel()
+		var v []string
+		e := serpent.EnumArrayOfChoices(&v, choices...)
+		require.NoError(t, e.Set("Table,js"))
+		require.Equal(t, []string{"table", "json"}, v)
+		require.NoError(t, e.Replace([]string{"JSON"}))
+		require.Equal(t, []string{"json"}, v)
+		require.Error(t, e.Append("xml"))
+	})
+
+	t.Run("DetailsByValue", func(t *testing.T) {
+		t.Parallel()
+		var v string
+		// Details describe some of the choices.
+		e := &serpent.Enum{
+			Choices: []string{"table", "csv"},
+			Details: choices[:1],
+			Value:   &v,
+		}
+		require.Equal(t, []serpent.EnumChoice{choices[0], {Value: "csv"}}, e.ChoiceDetails())
+		require.NoError(t, e.Set("csv"))
+		require.Error(t, e.Set("json"))
+
+		// Without choices, they come from the details.
+		e = &serpent.Enum{Details: choices, Value: &v}
+		require.NoError(t, e.Set("js"))
+		require.Equal(t, "json", v)
+		require.Equal(t, "enum[table\\|json\\|yml]", e.Type())
+
+		// Details of values that aren't choices are rejected.
+		cmd := &serpent.Command{
+			Use: "root",
+			Options: serpent.OptionSet{{
+				Name:  "format",
+				Flag:  "format",
+				Value: &serpent.Enum{Choices: []string{"table"}, Details: choices, Value: &v},
+			}},
+		}
+		require.ErrorContains(t, cmd.Invoke().Run(), `enum details describe "json"`)
+	})
+}
+
+func TestValueCloner(t *testing.T) {
+	t.Parallel()
+
+	var (
+		s   string
+		i   int64
+		ss  []string
+		e   string
+		ea  []string
+		fp  string
+		opt = serpent.OptionalOf(serpent.Int64Of(new(int64)))
+	)
+	tests := []struct {
+		name  string
+		value pflag.Value
+		input string
+	}{
+		{name: "String", value: serpent.StringOf(&s), input: "x"},
+		{name: "Int64", value: serpent.Int64Of(&i), input: "1"},
+		{name: "StringArray", value: serpent.StringArrayOf(&ss), input: "a,b"},
+		{name: "Enum", value: serpent.EnumOf(&e, "a", "b"), input: "b"},
+		{name: "EnumArray", value: serpent.EnumArrayOf(&ea, "a", "b"), input: "a"},
+		{name: "FilePath", value: serpent.FilePathOf(&fp), input: "/tmp/x"},
+		{name: "Optional", value: opt, input: "2"},
+		{name: "Validator", value: serpent.Validate(serpent.StringOf(new(string)), func(*serpent.String) error { return nil }), input: "v"},
+		{name: "Struct", value: &serpent.Struct[map[string]string]{Value: map[string]string{"a": "b"}}, input: "c: d"},
+		{name: "SemVer", value: &serpent.SemVer{}, input: "1.2.3"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			str := func(v pflag.Value) string {
+				if sv, ok := v.(pflag.SliceValue); ok {
+					return strings.Join(sv.GetSlice(), ",")
+				}
+				return v.String()
+			}
+			before := str(tt.value)
+			c, err := tt.value.(serpent.ValueCloner).CloneValue()
+			require.NoError(t, err)
+			require.Equal(t, before, str(c))
+			require.NoError(t, c.Set(tt.input))
+			require.NotEqual(t, before, str(c))
+			require.Equal(t, before, str(tt.value), "original changed")
+		})
+	}
+}